@@ -2,110 +2,412 @@ package agent
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/lucci-labs/luccibot/bus"
-	"google.golang.org/genai"
+	"github.com/lucci-labs/luccibot/config"
 )
 
+// maxToolIterations bounds the number of LLM round trips for a single message.
+const maxToolIterations = 8
+
+const systemPrompt = `You are LucciBot, a self-hosted crypto wallet assistant running in the user's terminal.
+Use the available tools to look up balances, quote and execute swaps, send tokens and inspect history.
+Never invent balances or transaction hashes; call a tool instead. Answer concisely.`
+
 // Agent represents the "Brain" of the application.
 type Agent struct {
-	Hub    *bus.Hub
-	Client *genai.Client
+	Hub *bus.Hub
+	// Provider is the LLM backend; the agent answers with a notice when it is nil.
+	Provider LLMProvider
+	// Tools are the skills declared to the LLM as callable functions.
+	Tools []Tool
+	// Specialists, when non-empty, makes the agent act as a manager that
+	// routes every request to one specialist.
+	Specialists *SpecialistRegistry
+	// Sessions persists conversations; history is kept in memory only when nil.
+	Sessions *SessionStore
+	// TokenBudget is the history size above which old turns are summarized.
+	// DefaultSessionTokenBudget is used when zero.
+	TokenBudget int
+	// Prices is used to compute the cost of every LLM response.
+	Prices config.PriceTable
+	// Ledger persists per-day usage totals; daily totals are not tracked when nil.
+	Ledger *UsageLedger
+
+	session *Session
+	// contextTokens is the size of the latest request and its reply.
+	contextTokens int
+	// requestID is the ID of the user message or command being handled;
+	// events and actions it causes carry it as their ParentID. Only the
+	// worker goroutine touches it.
+	requestID string
+
+	// mu guards cancelTurn, the cancel function of the running generation.
+	mu         sync.Mutex
+	cancelTurn context.CancelFunc
 }
 
-// NewAgent initializes a new Agent.
-func NewAgent(h *bus.Hub) *Agent {
-	var client *genai.Client
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey != "" {
-		var err error
-		// Initialize the client with the API key.
-		// Note: The actual initialization might depend on the specific version of the genai library.
-		// Assuming a standard pattern here, but will fall back to mock if initialization fails or is complex without context.
-		// For simplicity and safety in this scaffold, we'll just log if we were "supposed" to have one.
-		// The prompt asks to "Integrate google.golang.org/genai", so I will attempt a basic setup.
-		ctx := context.Background()
-		client, err = genai.NewClient(ctx, &genai.ClientConfig{
-			APIKey: apiKey,
-		})
-		if err != nil {
-			fmt.Printf("Failed to create GenAI client: %v\n", err)
-		}
-	}
+// toolFunc executes a single tool call and returns the response fed back to the model.
+type toolFunc func(ctx context.Context, name string, args map[string]any) map[string]any
 
+// NewAgent initializes a new Agent using the given provider.
+func NewAgent(h *bus.Hub, provider LLMProvider) *Agent {
 	return &Agent{
-		Hub:    h,
-		Client: client,
+		Hub:         h,
+		Provider:    provider,
+		Tools:       DefaultTools(),
+		Specialists: DefaultSpecialists(),
+		Prices:      config.DefaultPrices,
+		session:     NewSession(),
 	}
 }
 
 // Start begins the Agent's main loop.
+// Messages and commands are processed one at a time by a worker goroutine so
+// that "cancel" events can interrupt a running generation.
 func (a *Agent) Start(ctx context.Context) error {
 	// Notify that agent is running
-	a.Hub.Outbound <- bus.Event{
-		Type:    "log",
-		Payload: "Agent started. Waiting for input...",
-	}
+	a.Hub.Outbound <- bus.LogEvent("Agent started. Waiting for input...")
+
+	work := make(chan bus.Event, 10)
+	go a.worker(ctx, work)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case event := <-a.Hub.Inbound:
-			// Process user message
-			if event.Type == "user_message" {
-				msg, ok := event.Payload.(string)
-				if !ok {
-					continue
-				}
-				a.processMessage(ctx, msg)
+			a.Hub.RecordEvent(bus.RecordInbound, event)
+			if event.Type == bus.EventCancel {
+				a.cancelGeneration()
+				continue
+			}
+			if p, ok := event.Payload.(bus.CancelSkillPayload); ok {
+				a.cancelSkill(ctx, p.ActionID)
+				continue
+			}
+			select {
+			case work <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// worker processes user messages and commands sequentially.
+func (a *Agent) worker(ctx context.Context, work <-chan bus.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-work:
+			a.requestID = event.RequestID
+			switch p := event.Payload.(type) {
+			case bus.UserMessagePayload:
+				a.processMessage(ctx, p.Text)
+			case bus.CommandPayload:
+				a.handleCommand(p.Text)
 			}
 		}
 	}
 }
 
+// cancelGeneration aborts the running generation, if any.
+func (a *Agent) cancelGeneration() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cancelTurn != nil {
+		a.cancelTurn()
+	}
+}
+
+// cancelSkill asks the bridge to kill the skill running for an action, or
+// every running skill if actionID is empty.
+func (a *Agent) cancelSkill(ctx context.Context, actionID string) {
+	select {
+	case a.Hub.SkillCancel <- actionID:
+	case <-ctx.Done():
+	}
+}
+
+// processMessage sends the message to the LLM and runs the tool loop until
+// the model produces a final answer, streaming the answer text to the TUI.
 func (a *Agent) processMessage(ctx context.Context, msg string) {
-	// TODO: Use a.Client if available for more complex understanding.
-	// For now, we use the mock response/logic as requested for scaffolding.
-
-	// Test all kind of messages
-	a.Hub.Outbound <- bus.Event{Type: "log", Payload: "Processing: " + msg}
-	a.Hub.Outbound <- bus.Event{Type: "signed", Payload: "Processing: " + msg}
-	a.Hub.Outbound <- bus.Event{Type: "error", Payload: "Processing: " + msg}
-
-	// 2. Simple parsing logic (Mock "Brain")
-	lowerMsg := strings.ToLower(msg)
-	if strings.Contains(lowerMsg, "swap") {
-		parts := strings.Fields(lowerMsg)
-		// Very naive parsing: "swap 1 eth" -> Action: swap, Args: [1, eth]
-		// In a real scenario, the LLM would extract this structured data.
-
-		args := []string{}
-		if len(parts) > 1 {
-			args = parts[1:]
+	if a.Provider == nil {
+		a.respond("No LLM is configured. Set GEMINI_API_KEY, OPENAI_API_KEY or OPENAI_BASE_URL to enable the agent.")
+		return
+	}
+
+	turnCtx, cancel := context.WithCancel(ctx)
+	a.mu.Lock()
+	a.cancelTurn = cancel
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.cancelTurn = nil
+		a.mu.Unlock()
+		cancel()
+	}()
+
+	messageID := "msg-" + randomHex(6)
+	a.emit(bus.NewEvent(bus.ThinkingPayload{MessageID: messageID}))
+	onDelta := func(delta string) {
+		a.emit(bus.NewEvent(bus.ResponseDeltaPayload{MessageID: messageID, Text: delta}))
+	}
+
+	sess := a.session
+	history := append(sess.Turns, Message{Role: RoleUser, Content: msg})
+
+	answer, turns, err := a.answer(turnCtx, sess.Summary, history, onDelta)
+	a.emit(bus.NewEvent(bus.ResponseDonePayload{MessageID: messageID, Text: answer}))
+	if err != nil {
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			a.emit(bus.LogEvent("Generation cancelled."))
+			return
 		}
+		a.emit(bus.ErrorEvent("Agent failed: %v", err))
+		return
+	}
 
-		action := bus.Action{
-			SkillName: "swap",
-			Args:      args,
+	sess.Turns = append(history, turns...)
+	a.compact(ctx, sess)
+	a.saveSession()
+}
+
+// answer runs the manager when specialists are registered, or a single flat
+// tool loop over all tools otherwise. It returns the final answer and the
+// turns generated after history.
+func (a *Agent) answer(ctx context.Context, summary string, history []Message, onDelta func(string)) (string, []Message, error) {
+	if a.Specialists == nil || a.Specialists.Len() == 0 {
+		call := func(ctx context.Context, name string, args map[string]any) map[string]any {
+			a.emit(bus.LogEvent("Calling tool %s %s", name, encodeJSON(args)))
+			return a.callTool(ctx, name, args)
 		}
+		return a.runToolLoop(ctx, withSummary(systemPrompt, summary), a.Tools, history, call, onDelta)
+	}
+	prompt := withSummary(a.Specialists.managerPrompt(), summary)
+	return a.runToolLoop(ctx, prompt, a.Specialists.managerTools(), history, a.callSpecialist, onDelta)
+}
+
+// withSummary appends the summary of trimmed turns to a system prompt.
+func withSummary(prompt, summary string) string {
+	if summary == "" {
+		return prompt
+	}
+	return prompt + "\n\nSummary of the earlier conversation:\n" + summary
+}
+
+// callSpecialist handles a call_<name>_specialist tool call from the manager by
+// running the specialist's own tool loop on the task.
+func (a *Agent) callSpecialist(ctx context.Context, name string, args map[string]any) map[string]any {
+	s, ok := a.Specialists.byToolName(name)
+	if !ok {
+		return map[string]any{"error": fmt.Sprintf("unknown specialist tool %q", name)}
+	}
+	task, _ := args["task"].(string)
+	if task == "" {
+		return map[string]any{"error": "task is required"}
+	}
+
+	a.trace(s.Name, "handling: "+task)
+
+	tools := a.toolSubset(s.Tools)
+	call := func(ctx context.Context, name string, args map[string]any) map[string]any {
+		a.trace(s.Name, fmt.Sprintf("calling %s %s", name, encodeJSON(args)))
+		return a.callTool(ctx, name, args)
+	}
 
-		a.Hub.Outbound <- bus.Event{Type: "log", Payload: fmt.Sprintf("Identified intent: %s %v", action.SkillName, action.Args)}
-		a.Hub.ActionReq <- action
-	} else if strings.Contains(lowerMsg, "hello") {
-		a.Hub.Outbound <- bus.Event{Type: "response", Payload: "Hello! I am LucciBot. I can help you swap assets or sign transactions."}
-	} else {
-		// Fallback for unknown commands
-		if a.Client != nil {
-			// Here we would call the LLM.
-			// resp, err := a.Client.GenerateContent(...)
-			// For this scaffold, we'll just acknowledge we have the client but revert to mock.
-			a.Hub.Outbound <- bus.Event{Type: "response", Payload: "[LLM Connected] I heard you, but I am currently configured to only handle 'swap' commands in this scaffold."}
+	answer, _, err := a.runToolLoop(ctx, s.Prompt, tools, []Message{{Role: RoleUser, Content: task}}, call, nil)
+	if err != nil {
+		a.trace(s.Name, "failed: "+err.Error())
+		return map[string]any{"error": err.Error()}
+	}
+	a.trace(s.Name, "done")
+	return map[string]any{"specialist": s.Name, "result": answer}
+}
+
+// trace reports a specialist step on the Hub so the TUI can show who handled a request.
+func (a *Agent) trace(specialist, step string) {
+	a.emit(bus.NewEvent(bus.TracePayload{Specialist: specialist, Step: step}))
+}
+
+// toolSubset returns the agent tools whose names are listed, in the agent's order.
+func (a *Agent) toolSubset(names []string) []Tool {
+	allowed := make(map[string]bool, len(names))
+	for _, n := range names {
+		allowed[n] = true
+	}
+	var tools []Tool
+	for _, t := range a.Tools {
+		if allowed[t.Name] {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// runToolLoop iterates between the model and the tool handler: every tool
+// call returned by the model is executed with call and its result is fed
+// back, until the model answers with plain text. It returns the answer and
+// the assistant and tool turns appended to history. Answer text is streamed
+// to onDelta when it is not nil.
+func (a *Agent) runToolLoop(ctx context.Context, prompt string, tools []Tool, history []Message, call toolFunc, onDelta func(string)) (string, []Message, error) {
+	req := Request{
+		System:   prompt,
+		Tools:    tools,
+		Messages: append([]Message(nil), history...),
+	}
+
+	for i := 0; i < maxToolIterations; i++ {
+		var resp *Response
+		var err error
+		if onDelta != nil {
+			resp, err = a.Provider.GenerateStream(ctx, req, onDelta)
 		} else {
-			a.Hub.Outbound <- bus.Event{Type: "response", Payload: "I didn't understand that. Try 'swap 1 eth'."}
+			resp, err = a.Provider.Generate(ctx, req)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		a.recordUsage(resp.Usage)
+
+		reply := resp.Message
+		req.Messages = append(req.Messages, reply)
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, req.Messages[len(history):], nil
+		}
+
+		for _, tc := range reply.ToolCalls {
+			var result map[string]any
+			if !hasTool(tools, tc.Name) {
+				result = map[string]any{"error": fmt.Sprintf("unknown tool %q", tc.Name)}
+			} else {
+				result = call(ctx, tc.Name, tc.Args)
+			}
+			req.Messages = append(req.Messages, Message{
+				Role:       RoleTool,
+				Content:    encodeJSON(result),
+				ToolCallID: tc.ID,
+				Name:       tc.Name,
+			})
+		}
+	}
+
+	return "", nil, fmt.Errorf("no final answer after %d tool iterations", maxToolIterations)
+}
+
+// callTool dispatches a tool call to the Bridge and waits for its result.
+func (a *Agent) callTool(ctx context.Context, name string, args map[string]any) map[string]any {
+	resultChan := make(chan bus.ActionResult, 1)
+	action := bus.Action{
+		RequestID:  bus.NewRequestID(),
+		ParentID:   a.requestID,
+		SkillName:  name,
+		Args:       args,
+		ResultChan: resultChan,
+	}
+
+	select {
+	case <-ctx.Done():
+		return map[string]any{"error": ctx.Err().Error()}
+	case a.Hub.ActionReq <- action:
+	}
+
+	select {
+	case <-ctx.Done():
+		// Nobody waits for the result any more; stop the skill too.
+		select {
+		case a.Hub.SkillCancel <- action.RequestID:
+		default:
+		}
+		return map[string]any{"error": ctx.Err().Error()}
+	case result := <-resultChan:
+		if result.Error != nil {
+			return map[string]any{"error": result.Error.Error()}
+		}
+		response := map[string]any{"output": string(result.Output)}
+		if len(result.Signature) > 0 {
+			response["signature"] = string(result.Signature)
 		}
+		return response
 	}
 }
+
+func hasTool(tools []Tool, name string) bool {
+	for _, t := range tools {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeJSON renders tool arguments and results for logs and tool messages.
+func encodeJSON(v map[string]any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// recordUsage adds the usage of a response to the session and day totals
+// and publishes the updated stats.
+func (a *Agent) recordUsage(u Usage) {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		return
+	}
+
+	var cost float64
+	if price, ok := a.Prices.Lookup(a.Provider.Model()); ok {
+		cost = price.Cost(u.PromptTokens, u.CompletionTokens)
+	}
+
+	a.session.Usage.Add(u, cost)
+	a.contextTokens = u.PromptTokens + u.CompletionTokens
+	if a.Ledger != nil {
+		if err := a.Ledger.Add(time.Now(), u, cost); err != nil {
+			a.emit(bus.ErrorEvent("failed to record usage: %v", err))
+		}
+	}
+	a.publishUsage()
+}
+
+// publishUsage sends the current usage stats to the TUI.
+func (a *Agent) publishUsage() {
+	stats := bus.UsageStats{
+		SessionTokens: a.session.Usage.Tokens(),
+		SessionCost:   a.session.Usage.Cost,
+		ContextTokens: a.contextTokens,
+	}
+	if a.Provider != nil {
+		stats.Model = a.Provider.Model()
+		if price, ok := a.Prices.Lookup(stats.Model); ok {
+			stats.ContextWindow = price.ContextWindow
+		}
+	}
+	if a.Ledger != nil {
+		stats.DayCost = a.Ledger.Day(time.Now()).Cost
+	}
+	a.emit(bus.NewEvent(stats))
+}
+
+// emit sends an event caused by the request being handled to the TUI.
+func (a *Agent) emit(ev bus.Event) {
+	a.Hub.Outbound <- ev.WithParent(a.requestID)
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,379 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+// fakeGemini serves canned generateContent responses in order and records the requests.
+type fakeGemini struct {
+	responses []string
+	requests  []map[string]any
+}
+
+func (f *fakeGemini) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stream := strings.HasSuffix(r.URL.Path, ":streamGenerateContent")
+	if !stream && !strings.HasSuffix(r.URL.Path, ":generateContent") {
+		http.Error(w, "unexpected path "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	f.requests = append(f.requests, body)
+
+	if len(f.requests) > len(f.responses) {
+		http.Error(w, "no more responses", http.StatusInternalServerError)
+		return
+	}
+	resp := f.responses[len(f.requests)-1]
+	if stream {
+		// Each line of a canned response is sent as one server-sent event
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range strings.Split(resp, "\n") {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(resp))
+}
+
+func newTestAgent(t *testing.T, fake *fakeGemini) *Agent {
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	provider, err := NewGeminiProvider(context.Background(), "test-key", "gemini-test", srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	h := bus.NewHub()
+	return &Agent{Hub: h, Provider: provider, Tools: DefaultTools()}
+}
+
+func TestToolLoop(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_balance","args":{"chain":"ethereum"}}}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"You have 1 ETH."}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+
+	// Fake bridge answering the tool call
+	go func() {
+		action := <-a.Hub.ActionReq
+		if action.SkillName != "get_balance" {
+			t.Errorf("Expected 'get_balance', got '%s'", action.SkillName)
+		}
+		if action.Args["chain"] != "ethereum" {
+			t.Errorf("Expected chain 'ethereum', got '%v'", action.Args["chain"])
+		}
+		action.ResultChan <- bus.ActionResult{Output: []byte(`{"native":{"symbol":"ETH","balance":"1"}}`)}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	answer, _, err := a.answer(ctx, "", []Message{{Role: RoleUser, Content: "what is my balance?"}}, nil)
+	if err != nil {
+		t.Fatalf("answer failed: %v", err)
+	}
+	if answer != "You have 1 ETH." {
+		t.Errorf("Expected 'You have 1 ETH.', got '%s'", answer)
+	}
+
+	if len(fake.requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(fake.requests))
+	}
+
+	// The second request must carry the tool result back to the model
+	second, _ := json.Marshal(fake.requests[1])
+	if !strings.Contains(string(second), `"functionResponse"`) || !strings.Contains(string(second), `ETH`) {
+		t.Errorf("Expected function response in second request, got %s", second)
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_balance","args":{}}}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"You have 1 ETH."}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+	a.session = NewSession()
+
+	go func() {
+		action := <-a.Hub.ActionReq
+		if action.ParentID != "req-prompt" || action.RequestID == "" {
+			t.Errorf("Expected action caused by req-prompt, got %q/%q", action.RequestID, action.ParentID)
+		}
+		action.ResultChan <- bus.ActionResult{Output: []byte(`{}`)}
+	}()
+
+	// The worker sets the ID of the event being handled
+	a.requestID = "req-prompt"
+	a.processMessage(context.Background(), "balance?")
+
+	for len(a.Hub.Outbound) > 0 {
+		if ev := <-a.Hub.Outbound; ev.ParentID != "req-prompt" || ev.RequestID == "" {
+			t.Errorf("Expected %s event caused by req-prompt, got %q/%q", ev.Type, ev.RequestID, ev.ParentID)
+		}
+	}
+}
+
+func TestToolLoopUnknownTool(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"rm_rf","args":{}}}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"I cannot do that."}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+
+	answer, _, err := a.answer(context.Background(), "", []Message{{Role: RoleUser, Content: "delete everything"}}, nil)
+	if err != nil {
+		t.Fatalf("answer failed: %v", err)
+	}
+	if answer != "I cannot do that." {
+		t.Errorf("Expected 'I cannot do that.', got '%s'", answer)
+	}
+	select {
+	case action := <-a.Hub.ActionReq:
+		t.Errorf("Unknown tool must not reach the bridge, got %s", action.SkillName)
+	default:
+	}
+
+	second, _ := json.Marshal(fake.requests[1])
+	if !strings.Contains(string(second), `unknown tool`) {
+		t.Errorf("Expected unknown tool error in second request, got %s", second)
+	}
+}
+
+func TestManagerRoutesToSpecialist(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		// Manager routes to the trading specialist
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"call_trading_specialist","args":{"task":"get the ethereum balance"}}}]}}]}`,
+		// Specialist calls its tool
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_balance","args":{"chain":"ethereum"}}}]}}]}`,
+		// Specialist answers the manager
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Balance is 1 ETH."}]}}]}`,
+		// Manager answers the user
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"You have 1 ETH."}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+	a.Specialists = DefaultSpecialists()
+
+	go func() {
+		action := <-a.Hub.ActionReq
+		action.ResultChan <- bus.ActionResult{Output: []byte(`{"native":{"symbol":"ETH","balance":"1"}}`)}
+	}()
+
+	answer, _, err := a.answer(context.Background(), "", []Message{{Role: RoleUser, Content: "what is my balance?"}}, nil)
+	if err != nil {
+		t.Fatalf("answer failed: %v", err)
+	}
+	if answer != "You have 1 ETH." {
+		t.Errorf("Expected 'You have 1 ETH.', got '%s'", answer)
+	}
+
+	// The manager only sees routing tools, the specialist only its subset
+	manager, _ := json.Marshal(fake.requests[0])
+	if !strings.Contains(string(manager), "call_history_specialist") || strings.Contains(string(manager), `"get_balance"`) {
+		t.Errorf("Unexpected manager tools: %s", manager)
+	}
+	specialist, _ := json.Marshal(fake.requests[1])
+	if !strings.Contains(string(specialist), `"swap_quote"`) || strings.Contains(string(specialist), `"get_approvals"`) {
+		t.Errorf("Unexpected specialist tools: %s", specialist)
+	}
+
+	// The specialist trace is emitted on the Hub
+	var traced bool
+	for len(a.Hub.Outbound) > 0 {
+		ev := <-a.Hub.Outbound
+		if p, ok := ev.Payload.(bus.TracePayload); ok && p.Specialist == "trading" {
+			traced = true
+		}
+	}
+	if !traced {
+		t.Error("Expected a trace event from the trading specialist")
+	}
+}
+
+func TestSkillsReachSpecialists(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		// Manager routes to the skills specialist
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"call_skills_specialist","args":{"task":"check the base fee"}}}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Base fee is 1 gwei."}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"The base fee is 1 gwei."}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+	a.Specialists = DefaultSpecialists()
+	skills := []Tool{
+		{Name: "base_tool", Description: "Reads the base fee.", Parameters: objectSchema(nil)},
+		{Name: "gas_alert", Description: "Alerts on high gas.", Parameters: objectSchema(nil)},
+	}
+	a.Tools = MergeTools(a.Tools, skills)
+	a.Specialists.AddTool("", skills[0])
+	a.Specialists.AddTool("trading", skills[1])
+
+	if _, _, err := a.answer(context.Background(), "", []Message{{Role: RoleUser, Content: "what is the base fee?"}}, nil); err != nil {
+		t.Fatalf("answer failed: %v", err)
+	}
+
+	manager, _ := json.Marshal(fake.requests[0])
+	if !strings.Contains(string(manager), "base_tool (Reads the base fee)") {
+		t.Errorf("Expected the manager to see the skill, got: %s", manager)
+	}
+	specialist, _ := json.Marshal(fake.requests[1])
+	if !strings.Contains(string(specialist), `"base_tool"`) || strings.Contains(string(specialist), `"swap"`) {
+		t.Errorf("Unexpected skills specialist tools: %s", specialist)
+	}
+	if s, _ := a.Specialists.Get("trading"); !slices.Contains(s.Tools, "gas_alert") {
+		t.Errorf("Expected the trading specialist to get gas_alert, got %v", s.Tools)
+	}
+	if s, _ := DefaultSpecialists().Get("trading"); slices.Contains(s.Tools, "gas_alert") {
+		t.Error("Expected the default specialists to be left untouched")
+	}
+}
+
+func TestSpecialistRegistry(t *testing.T) {
+	r := NewSpecialistRegistry()
+	if err := r.Register(Specialist{Name: "trading"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := r.Register(Specialist{Name: "trading"}); err == nil {
+		t.Error("Expected duplicate registration to fail")
+	}
+	if err := r.Register(Specialist{}); err == nil {
+		t.Error("Expected empty name to fail")
+	}
+
+	s, ok := r.byToolName("call_trading_specialist")
+	if !ok || s.Name != "trading" {
+		t.Errorf("Expected 'trading', got '%s'", s.Name)
+	}
+	if _, ok := r.byToolName("call_unknown_specialist"); ok {
+		t.Error("Expected unknown specialist lookup to fail")
+	}
+}
+
+func TestStreamingResponse(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}]}` + "\n" +
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":", world"}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+	a.session = NewSession()
+
+	a.processMessage(context.Background(), "hi")
+
+	var id, streamed, final string
+	for len(a.Hub.Outbound) > 0 {
+		ev := <-a.Hub.Outbound
+		switch p := ev.Payload.(type) {
+		case bus.ThinkingPayload:
+			id = p.MessageID
+		case bus.ResponseDeltaPayload:
+			if p.MessageID != id {
+				t.Errorf("Expected message ID '%s', got '%s'", id, p.MessageID)
+			}
+			streamed += p.Text
+		case bus.ResponseDonePayload:
+			final = p.Text
+		}
+	}
+
+	if id == "" {
+		t.Error("Expected a thinking event with a message ID")
+	}
+	if streamed != "Hello, world" || final != "Hello, world" {
+		t.Errorf("Expected 'Hello, world', got streamed '%s' and final '%s'", streamed, final)
+	}
+}
+
+func TestCancelGeneration(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	provider, _ := NewGeminiProvider(context.Background(), "test-key", "gemini-test", srv.URL)
+	a := &Agent{Hub: bus.NewHub(), Provider: provider, session: NewSession()}
+
+	done := make(chan struct{})
+	go func() {
+		a.processMessage(context.Background(), "hi")
+		close(done)
+	}()
+
+	// Wait for the generation to start, then cancel it
+	if ev := <-a.Hub.Outbound; ev.Type != bus.EventThinking {
+		t.Fatalf("Expected thinking event, got '%s'", ev.Type)
+	}
+	for {
+		a.mu.Lock()
+		running := a.cancelTurn != nil
+		a.mu.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	a.cancelGeneration()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Generation was not cancelled")
+	}
+	if len(a.session.Turns) != 0 {
+		t.Errorf("Cancelled turn must not be recorded, got %d turns", len(a.session.Turns))
+	}
+}
+
+func TestCallToolCancelsSkill(t *testing.T) {
+	a := &Agent{Hub: bus.NewHub(), session: NewSession()}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	actions := make(chan bus.Action, 1)
+	go func() {
+		action := <-a.Hub.ActionReq
+		actions <- action
+		cancel()
+	}()
+
+	result := a.callTool(ctx, "swap", nil)
+	if result["error"] != context.Canceled.Error() {
+		t.Errorf("Expected cancellation error, got %v", result)
+	}
+	action := <-actions
+	select {
+	case id := <-a.Hub.SkillCancel:
+		if id != action.RequestID {
+			t.Errorf("Expected cancel of %s, got %s", action.RequestID, id)
+		}
+	default:
+		t.Error("Expected the running skill to be cancelled")
+	}
+}
+
+func TestMergeTools(t *testing.T) {
+	base := []Tool{{Name: "get_balance"}, {Name: "send"}}
+	extra := []Tool{{Name: "send", Description: "from manifest"}, {Name: "bridge_tokens"}}
+
+	merged := MergeTools(base, extra)
+	if len(merged) != 3 || merged[1].Description != "from manifest" || merged[2].Name != "bridge_tokens" {
+		t.Errorf("Unexpected merged tools: %+v", merged)
+	}
+	if base[1].Description != "" {
+		t.Error("Expected base to be left untouched")
+	}
+}
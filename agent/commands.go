@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+const summaryPrompt = `Summarize the following conversation between a user and LucciBot, a crypto wallet assistant.
+Keep balances, addresses, amounts, transaction hashes and open requests. Be brief.`
+
+// handleCommand executes a slash command typed in the TUI.
+func (a *Agent) handleCommand(input string) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch fields[0] {
+	case "/new":
+		a.saveSession()
+		a.session = NewSession()
+		a.contextTokens = 0
+		a.respond(fmt.Sprintf("Started new session %s.", a.session.ID))
+		a.publishUsage()
+	case "/sessions":
+		a.listSessions()
+	case "/resume":
+		if len(fields) < 2 {
+			a.fail("usage: /resume <id>")
+			return
+		}
+		a.resumeSession(fields[1])
+	default:
+		a.fail(fmt.Sprintf("unknown command %s (try /new, /sessions, /resume <id> or /cancel [action id])", fields[0]))
+	}
+}
+
+func (a *Agent) listSessions() {
+	if a.Sessions == nil {
+		a.fail("session storage is disabled")
+		return
+	}
+	sessions, err := a.Sessions.List()
+	if err != nil {
+		a.fail(fmt.Sprintf("failed to list sessions: %v", err))
+		return
+	}
+	if len(sessions) == 0 {
+		a.respond("No saved sessions yet.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Sessions:\n")
+	for _, sess := range sessions {
+		marker := " "
+		if sess.ID == a.session.ID {
+			marker = "*"
+		}
+		fmt.Fprintf(&sb, "%s %s  %s  %d turns  %s\n", marker, sess.ID, sess.UpdatedAt.Format("2006-01-02 15:04"), len(sess.Turns), sess.Title())
+	}
+	a.respond(strings.TrimSuffix(sb.String(), "\n"))
+}
+
+func (a *Agent) resumeSession(id string) {
+	if a.Sessions == nil {
+		a.fail("session storage is disabled")
+		return
+	}
+	sess, err := a.Sessions.Load(id)
+	if err != nil {
+		a.fail(err.Error())
+		return
+	}
+	a.saveSession()
+	a.session = sess
+	a.contextTokens = estimateTokens(sess.Summary, sess.Turns)
+	a.respond(fmt.Sprintf("Resumed session %s (%d turns): %s", sess.ID, len(sess.Turns), sess.Title()))
+	a.publishUsage()
+}
+
+// saveSession persists the current session if it has any turns.
+func (a *Agent) saveSession() {
+	if a.Sessions == nil || len(a.session.Turns) == 0 {
+		return
+	}
+	if err := a.Sessions.Save(a.session); err != nil {
+		a.fail(fmt.Sprintf("failed to save session: %v", err))
+	}
+}
+
+// compact trims the oldest turns once the session exceeds the token budget,
+// folding them into the session summary.
+func (a *Agent) compact(ctx context.Context, sess *Session) {
+	budget := a.TokenBudget
+	if budget <= 0 {
+		budget = DefaultSessionTokenBudget
+	}
+	if estimateTokens(sess.Summary, sess.Turns) <= budget {
+		return
+	}
+
+	cut := trimPoint(sess.Turns, budget)
+	if cut == 0 {
+		return
+	}
+	dropped := sess.Turns[:cut]
+	sess.Turns = append([]Message(nil), sess.Turns[cut:]...)
+
+	summary, err := a.summarize(ctx, sess.Summary, dropped)
+	if err != nil {
+		a.emit(bus.LogEvent("Trimmed %d old turns without summary: %v", len(dropped), err))
+		return
+	}
+	sess.Summary = summary
+	a.emit(bus.LogEvent("Summarized %d old turns to stay within the token budget", len(dropped)))
+}
+
+// summarize asks the provider to condense the dropped turns, together with
+// the previous summary, into a new summary.
+func (a *Agent) summarize(ctx context.Context, previous string, turns []Message) (string, error) {
+	var sb strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&sb, "Earlier summary: %s\n\n", previous)
+	}
+	for _, t := range turns {
+		switch {
+		case t.Role == RoleTool:
+			fmt.Fprintf(&sb, "tool %s: %s\n", t.Name, t.Content)
+		case len(t.ToolCalls) > 0:
+			for _, tc := range t.ToolCalls {
+				fmt.Fprintf(&sb, "assistant called %s %s\n", tc.Name, encodeJSON(tc.Args))
+			}
+		default:
+			fmt.Fprintf(&sb, "%s: %s\n", t.Role, t.Content)
+		}
+	}
+
+	resp, err := a.Provider.Generate(ctx, Request{
+		System:   summaryPrompt,
+		Messages: []Message{{Role: RoleUser, Content: sb.String()}},
+	})
+	if err != nil {
+		return previous, err
+	}
+	a.recordUsage(resp.Usage)
+	return resp.Message.Content, nil
+}
+
+func (a *Agent) respond(text string) {
+	a.emit(bus.NewEvent(bus.ResponsePayload{Text: text}))
+}
+
+func (a *Agent) fail(text string) {
+	a.emit(bus.NewEvent(bus.ErrorPayload{Message: text}))
+}
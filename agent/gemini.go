@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// GeminiProvider implements LLMProvider on top of the Gemini API.
+type GeminiProvider struct {
+	client *genai.Client
+	model  string
+}
+
+// NewGeminiProvider creates a Gemini provider. baseURL is optional and
+// overrides the API endpoint.
+func NewGeminiProvider(ctx context.Context, apiKey, model, baseURL string) (*GeminiProvider, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:      apiKey,
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: baseURL},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GenAI client: %w", err)
+	}
+	if model == "" {
+		model = DefaultModel
+	}
+	return &GeminiProvider{client: client, model: model}, nil
+}
+
+func (p *GeminiProvider) Name() string  { return "google" }
+func (p *GeminiProvider) Model() string { return p.model }
+
+// Generate implements LLMProvider.
+func (p *GeminiProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.client.Models.GenerateContent(ctx, p.model, toGeminiContents(req.Messages), geminiConfig(req))
+	if err != nil {
+		return nil, fmt.Errorf("generate content: %w", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil, fmt.Errorf("model returned no candidates")
+	}
+
+	return &Response{Message: fromGeminiContent(resp.Candidates[0].Content), Usage: geminiUsage(resp)}, nil
+}
+
+// GenerateStream implements LLMProvider.
+func (p *GeminiProvider) GenerateStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	merged := &genai.Content{Role: genai.RoleModel}
+	var usage Usage
+	stream := p.client.Models.GenerateContentStream(ctx, p.model, toGeminiContents(req.Messages), geminiConfig(req))
+	for chunk, err := range stream {
+		if err != nil {
+			return nil, fmt.Errorf("generate content stream: %w", err)
+		}
+		// Every chunk reports the cumulative usage so far
+		if chunk.UsageMetadata != nil {
+			usage = geminiUsage(chunk)
+		}
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text != "" && !part.Thought && onDelta != nil {
+				onDelta(part.Text)
+			}
+			merged.Parts = append(merged.Parts, part)
+		}
+	}
+	if len(merged.Parts) == 0 {
+		return nil, fmt.Errorf("model returned no candidates")
+	}
+
+	return &Response{Message: fromGeminiContent(merged), Usage: usage}, nil
+}
+
+// geminiUsage extracts token usage; thinking tokens are billed as output.
+func geminiUsage(resp *genai.GenerateContentResponse) Usage {
+	if resp.UsageMetadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+		CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount + resp.UsageMetadata.ThoughtsTokenCount),
+	}
+}
+
+func geminiConfig(req Request) *genai.GenerateContentConfig {
+	config := &genai.GenerateContentConfig{}
+	if req.System != "" {
+		config.SystemInstruction = genai.NewContentFromText(req.System, genai.RoleUser)
+	}
+	if len(req.Tools) > 0 {
+		config.Tools = []*genai.Tool{{FunctionDeclarations: functionDeclarations(req.Tools)}}
+	}
+	return config
+}
+
+// toGeminiContents converts the conversation, merging consecutive tool
+// results into a single user content as Gemini expects.
+func toGeminiContents(messages []Message) []*genai.Content {
+	var contents []*genai.Content
+	for _, m := range messages {
+		switch m.Role {
+		case RoleUser:
+			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleUser))
+		case RoleAssistant:
+			var parts []*genai.Part
+			if m.Content != "" {
+				parts = append(parts, genai.NewPartFromText(m.Content))
+			}
+			for _, call := range m.ToolCalls {
+				part := genai.NewPartFromFunctionCall(call.Name, call.Args)
+				part.FunctionCall.ID = call.ID
+				parts = append(parts, part)
+			}
+			contents = append(contents, genai.NewContentFromParts(parts, genai.RoleModel))
+		case RoleTool:
+			part := genai.NewPartFromFunctionResponse(m.Name, decodeToolResult(m.Content))
+			part.FunctionResponse.ID = m.ToolCallID
+
+			last := len(contents) - 1
+			if last >= 0 && contents[last].Role == genai.RoleUser && isFunctionResponse(contents[last]) {
+				contents[last].Parts = append(contents[last].Parts, part)
+			} else {
+				contents = append(contents, genai.NewContentFromParts([]*genai.Part{part}, genai.RoleUser))
+			}
+		}
+	}
+	return contents
+}
+
+func isFunctionResponse(content *genai.Content) bool {
+	return len(content.Parts) > 0 && content.Parts[0].FunctionResponse != nil
+}
+
+// fromGeminiContent converts a model content into an assistant message.
+func fromGeminiContent(content *genai.Content) Message {
+	msg := Message{Role: RoleAssistant}
+	var sb strings.Builder
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:   part.FunctionCall.ID,
+				Name: part.FunctionCall.Name,
+				Args: part.FunctionCall.Args,
+			})
+		case part.Text != "" && !part.Thought:
+			sb.WriteString(part.Text)
+		}
+	}
+	msg.Content = sb.String()
+	return msg
+}
+
+// functionDeclarations converts the tools into Gemini function declarations.
+func functionDeclarations(tools []Tool) []*genai.FunctionDeclaration {
+	decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:                 t.Name,
+			Description:          t.Description,
+			ParametersJsonSchema: t.Parameters,
+		})
+	}
+	return decls
+}
+
+// decodeToolResult turns a JSON encoded tool result back into an object.
+func decodeToolResult(content string) map[string]any {
+	var result map[string]any
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return map[string]any{"output": content}
+	}
+	return result
+}
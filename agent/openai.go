@@ -0,0 +1,329 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultOpenAIBaseURL is the endpoint used when no base URL is configured.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIProvider implements LLMProvider for any server speaking the OpenAI
+// chat completions API, including self-hosted llama.cpp and Ollama servers.
+type OpenAIProvider struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible provider. apiKey may be empty
+// for local servers; baseURL defaults to DefaultOpenAIBaseURL.
+func NewOpenAIProvider(apiKey, model, baseURL string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIProvider{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  http.DefaultClient,
+	}
+}
+
+func (p *OpenAIProvider) Name() string  { return "openai" }
+func (p *OpenAIProvider) Model() string { return p.model }
+
+// Wire types of the chat completions API.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Parameters  map[string]any `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Tools    []openAITool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream,omitempty"`
+	// StreamOptions asks for a final chunk carrying the token usage.
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// openAIChunk is a server-sent event of a streamed completion.
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Generate implements LLMProvider.
+func (p *OpenAIProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, p.buildRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var out openAIResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if out.Error != nil {
+		return nil, fmt.Errorf("chat completions error (status %d): %s", resp.StatusCode, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completions returned status %d", resp.StatusCode)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("model returned no choices")
+	}
+
+	msg, err := fromOpenAIMessage(out.Choices[0].Message)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Message: msg, Usage: out.Usage.toUsage()}, nil
+}
+
+// maxStreamToolCalls bounds the tool call index of a stream chunk, which
+// sizes the calls accumulated from the stream.
+const maxStreamToolCalls = 128
+
+// GenerateStream implements LLMProvider using server-sent events.
+func (p *OpenAIProvider) GenerateStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	body := p.buildRequest(req)
+	body.Stream = true
+	body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	resp, err := p.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		var out openAIResponse
+		if json.Unmarshal(data, &out) == nil && out.Error != nil {
+			return nil, fmt.Errorf("chat completions error (status %d): %s", resp.StatusCode, out.Error.Message)
+		}
+		return nil, fmt.Errorf("chat completions returned status %d", resp.StatusCode)
+	}
+
+	// Tool call fragments are accumulated by index.
+	var content strings.Builder
+	var calls []openAIToolCall
+	var usage Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("chat completions error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.toUsage()
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if onDelta != nil {
+				onDelta(delta.Content)
+			}
+		}
+		for _, tc := range delta.ToolCalls {
+			if tc.Index < 0 || tc.Index >= maxStreamToolCalls {
+				return nil, fmt.Errorf("stream chunk has invalid tool call index %d", tc.Index)
+			}
+			for len(calls) <= tc.Index {
+				calls = append(calls, openAIToolCall{Type: "function"})
+			}
+			if tc.ID != "" {
+				calls[tc.Index].ID = tc.ID
+			}
+			calls[tc.Index].Function.Name += tc.Function.Name
+			calls[tc.Index].Function.Arguments += tc.Function.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	text := content.String()
+	msg, err := fromOpenAIMessage(openAIMessage{Role: "assistant", Content: &text, ToolCalls: calls})
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Message: msg, Usage: usage}, nil
+}
+
+// post sends a chat completions request.
+func (p *OpenAIProvider) post(ctx context.Context, body openAIRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat completions request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func (p *OpenAIProvider) buildRequest(req Request) openAIRequest {
+	out := openAIRequest{Model: p.model}
+
+	if req.System != "" {
+		out.Messages = append(out.Messages, openAIMessage{Role: "system", Content: &req.System})
+	}
+	for _, m := range req.Messages {
+		content := m.Content
+		msg := openAIMessage{Role: string(m.Role), Content: &content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			var tc openAIToolCall
+			tc.ID = call.ID
+			tc.Type = "function"
+			tc.Function.Name = call.Name
+			args, _ := json.Marshal(call.Args)
+			tc.Function.Arguments = string(args)
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		if len(msg.ToolCalls) > 0 && content == "" {
+			msg.Content = nil
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+
+	for _, t := range req.Tools {
+		var tool openAITool
+		tool.Type = "function"
+		tool.Function.Name = t.Name
+		tool.Function.Description = t.Description
+		tool.Function.Parameters = t.Parameters
+		out.Tools = append(out.Tools, tool)
+	}
+	return out
+}
+
+func fromOpenAIMessage(m openAIMessage) (Message, error) {
+	msg := Message{Role: RoleAssistant}
+	if m.Content != nil {
+		msg.Content = *m.Content
+	}
+	for i, tc := range m.ToolCalls {
+		args := map[string]any{}
+		if tc.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+				return Message{}, fmt.Errorf("invalid arguments for tool %s: %w", tc.Function.Name, err)
+			}
+		}
+		id := tc.ID
+		if id == "" {
+			// Some local servers omit call IDs; the API requires them on replay.
+			id = fmt.Sprintf("call_%d", i)
+		}
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{ID: id, Name: tc.Function.Name, Args: args})
+	}
+	return msg, nil
+}
+
+func (u *openAIUsage) toUsage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
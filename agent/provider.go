@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lucci-labs/luccibot/config"
+)
+
+// Role identifies the author of a conversation message.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	ID   string         `json:"id"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+// Message is a provider-agnostic conversation turn.
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content,omitempty"`
+	// ToolCalls are set on assistant messages that request tool executions.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID and Name identify the call answered by a tool message,
+	// whose Content is the JSON encoded result.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// Request is a single generation request.
+type Request struct {
+	System   string
+	Messages []Message
+	Tools    []Tool
+}
+
+// Response is the model's reply to a Request.
+type Response struct {
+	Message Message
+	Usage   Usage
+}
+
+// LLMProvider is implemented by every LLM backend.
+type LLMProvider interface {
+	// Name returns the provider name, e.g. "google" or "openai".
+	Name() string
+	// Model returns the model used for generation.
+	Model() string
+	// Generate sends the conversation to the model and returns its reply.
+	Generate(ctx context.Context, req Request) (*Response, error)
+	// GenerateStream is like Generate but calls onDelta with every chunk of
+	// answer text as it arrives. The returned Response holds the full reply.
+	GenerateStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error)
+}
+
+// Default models per provider, used when Config.ActiveModel does not name one.
+const (
+	DefaultModel       = "gemini-2.5-pro"
+	DefaultOpenAIModel = "gpt-4o-mini"
+)
+
+// NewProvider selects and creates the provider for Config.ActiveModel.
+//
+// ActiveModel is either "<provider>/<model>" (e.g. "openai/llama3.1:8b") or a
+// bare model name, in which case "gemini-*" models use Google and anything
+// else uses the OpenAI-compatible backend. An empty ActiveModel picks the
+// first provider that has credentials or a base URL configured.
+func NewProvider(ctx context.Context, cfg *config.Config) (LLMProvider, error) {
+	provider, model := parseActiveModel(cfg)
+
+	switch provider {
+	case "google":
+		key := cfg.GetProviderKey("google")
+		if key == "" {
+			return nil, fmt.Errorf("no API key configured for google; set GEMINI_API_KEY")
+		}
+		return NewGeminiProvider(ctx, key, model, cfg.GetProviderURL("google"))
+	case "openai":
+		key := cfg.GetProviderKey("openai")
+		baseURL := cfg.GetProviderURL("openai")
+		if key == "" && baseURL == "" {
+			return nil, fmt.Errorf("no API key or base URL configured for openai; set OPENAI_API_KEY or OPENAI_BASE_URL")
+		}
+		return NewOpenAIProvider(key, model, baseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+}
+
+// parseActiveModel splits Config.ActiveModel into a provider and a model.
+func parseActiveModel(cfg *config.Config) (string, string) {
+	active := cfg.GetActiveModel()
+
+	if prefix, model, ok := strings.Cut(active, "/"); ok {
+		switch prefix {
+		case "google", "gemini":
+			return "google", model
+		case "openai":
+			return "openai", model
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(active, "gemini"):
+		return "google", active
+	case active != "":
+		return "openai", active
+	case cfg.GetProviderKey("google") != "":
+		return "google", DefaultModel
+	case cfg.GetProviderKey("openai") != "" || cfg.GetProviderURL("openai") != "":
+		return "openai", DefaultOpenAIModel
+	default:
+		return "google", DefaultModel
+	}
+}
@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucci-labs/luccibot/bus"
+	"github.com/lucci-labs/luccibot/config"
+)
+
+// fakeOpenAI serves canned chat completions responses in order and records the requests.
+type fakeOpenAI struct {
+	responses []string
+	requests  []openAIRequest
+	auth      string
+}
+
+func (f *fakeOpenAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/chat/completions" {
+		http.Error(w, "unexpected path "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+	f.auth = r.Header.Get("Authorization")
+
+	var body openAIRequest
+	json.NewDecoder(r.Body).Decode(&body)
+	f.requests = append(f.requests, body)
+
+	if len(f.requests) > len(f.responses) {
+		http.Error(w, `{"error":{"message":"no more responses"}}`, http.StatusInternalServerError)
+		return
+	}
+	resp := f.responses[len(f.requests)-1]
+	if body.Stream {
+		// Each line of a canned response is sent as one server-sent event
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range strings.Split(resp, "\n") {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(resp))
+}
+
+func TestOpenAIToolLoop(t *testing.T) {
+	fake := &fakeOpenAI{responses: []string{
+		`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_balance","arguments":"{\"chain\":\"base\"}"}}]}}]}`,
+		`{"choices":[{"message":{"role":"assistant","content":"You have 2 ETH on Base."}}]}`,
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	// A local server needs no API key
+	a := &Agent{
+		Hub:      bus.NewHub(),
+		Provider: NewOpenAIProvider("", "llama3.1", srv.URL+"/v1"),
+		Tools:    DefaultTools(),
+	}
+
+	go func() {
+		action := <-a.Hub.ActionReq
+		if action.Args["chain"] != "base" {
+			t.Errorf("Expected chain 'base', got '%v'", action.Args["chain"])
+		}
+		action.ResultChan <- bus.ActionResult{Output: []byte(`{"balance":"2"}`)}
+	}()
+
+	answer, _, err := a.answer(context.Background(), "", []Message{{Role: RoleUser, Content: "balance on base?"}}, nil)
+	if err != nil {
+		t.Fatalf("answer failed: %v", err)
+	}
+	if answer != "You have 2 ETH on Base." {
+		t.Errorf("Expected 'You have 2 ETH on Base.', got '%s'", answer)
+	}
+	if fake.auth != "" {
+		t.Errorf("Expected no Authorization header, got '%s'", fake.auth)
+	}
+
+	// The tool result is replayed with the matching call ID
+	second := fake.requests[1]
+	if second.Model != "llama3.1" {
+		t.Errorf("Expected model 'llama3.1', got '%s'", second.Model)
+	}
+	last := second.Messages[len(second.Messages)-1]
+	if last.Role != "tool" || last.ToolCallID != "call_1" {
+		t.Errorf("Expected tool message for call_1, got %+v", last)
+	}
+	if len(second.Tools) == 0 {
+		t.Error("Expected tools to be declared")
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	fake := &fakeOpenAI{responses: []string{
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_9","function":{"name":"send","arguments":"{\"to\":"}}]}}]}` + "\n" +
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"0xabc\"}"}}]}}]}`,
+		`{"choices":[{"delta":{"content":"Sent "}}]}` + "\n" + `{"choices":[{"delta":{"content":"to 0xabc."}}]}`,
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p := NewOpenAIProvider("sk-test", "gpt-test", srv.URL+"/v1")
+
+	// Tool call arguments arrive in fragments
+	resp, err := p.GenerateStream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "send"}}}, nil)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].ID != "call_9" || resp.Message.ToolCalls[0].Args["to"] != "0xabc" {
+		t.Errorf("Unexpected tool calls: %+v", resp.Message.ToolCalls)
+	}
+	if fake.auth != "Bearer sk-test" {
+		t.Errorf("Expected 'Bearer sk-test', got '%s'", fake.auth)
+	}
+
+	// Text deltas are forwarded as they arrive
+	var deltas []string
+	resp, err = p.GenerateStream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "send"}}}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if len(deltas) != 2 || resp.Message.Content != "Sent to 0xabc." {
+		t.Errorf("Unexpected stream result: %v / '%s'", deltas, resp.Message.Content)
+	}
+}
+
+func TestOpenAIStreamToolCallIndex(t *testing.T) {
+	fake := &fakeOpenAI{responses: []string{
+		`{"choices":[{"delta":{"tool_calls":[{"index":-1,"id":"call_1","function":{"name":"send"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1000000000,"id":"call_1","function":{"name":"send"}}]}}]}`,
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	p := NewOpenAIProvider("", "gpt-test", srv.URL+"/v1")
+
+	for range fake.responses {
+		_, err := p.GenerateStream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "send"}}}, nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid tool call index") {
+			t.Errorf("Expected an invalid index to fail, got %v", err)
+		}
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		active   string
+		keys     map[string]string
+		urls     map[string]string
+		provider string
+		model    string
+	}{
+		{"gemini-2.5-flash", map[string]string{"google": "k"}, nil, "google", "gemini-2.5-flash"},
+		{"openai/llama3.1:8b", nil, map[string]string{"openai": "http://localhost:11434/v1"}, "openai", "llama3.1:8b"},
+		{"gpt-4o", map[string]string{"openai": "k"}, nil, "openai", "gpt-4o"},
+		{"", map[string]string{"openai": "k"}, nil, "openai", DefaultOpenAIModel},
+		{"", map[string]string{"google": "k"}, nil, "google", DefaultModel},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{ProviderKeys: tt.keys, ProviderURLs: tt.urls, ActiveModel: tt.active}
+		p, err := NewProvider(context.Background(), cfg)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.active, err)
+			continue
+		}
+		if p.Name() != tt.provider || p.Model() != tt.model {
+			t.Errorf("%q: expected %s/%s, got %s/%s", tt.active, tt.provider, tt.model, p.Name(), p.Model())
+		}
+	}
+
+	// No credentials at all
+	if _, err := NewProvider(context.Background(), &config.Config{ActiveModel: "gpt-4o"}); err == nil {
+		t.Error("Expected error without credentials")
+	}
+}
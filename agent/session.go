@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSessionTokenBudget is the history size, in estimated tokens, above
+// which old turns are summarized.
+const DefaultSessionTokenBudget = 32000
+
+// Session is a persisted multi-turn conversation.
+type Session struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Summary condenses the turns that were trimmed from the history.
+	Summary string    `json:"summary,omitempty"`
+	Turns   []Message `json:"turns"`
+	// Usage is the token usage and cost of the session so far.
+	Usage UsageTotals `json:"usage"`
+}
+
+// Title returns the first user message, used to identify the session in listings.
+func (s *Session) Title() string {
+	for _, t := range s.Turns {
+		if t.Role == RoleUser {
+			title := strings.Join(strings.Fields(t.Content), " ")
+			if r := []rune(title); len(r) > 48 {
+				title = string(r[:45]) + "..."
+			}
+			return title
+		}
+	}
+	return "(empty)"
+}
+
+// SessionStore persists sessions as JSON files in a directory.
+type SessionStore struct {
+	dir string
+}
+
+// NewSessionStore creates a store in dir, creating the directory if needed.
+func NewSessionStore(dir string) (*SessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &SessionStore{dir: dir}, nil
+}
+
+// DefaultSessionDir returns the default session directory.
+// Usually ~/.luccibot/sessions
+func DefaultSessionDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".luccibot", "sessions"), nil
+}
+
+// NewSession returns a fresh, unsaved session.
+func NewSession() *Session {
+	now := time.Now()
+	return &Session{
+		ID:        now.Format("20060102-150405") + "-" + randomHex(3),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Save writes the session to disk.
+func (s *SessionStore) Save(sess *Session) error {
+	sess.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := os.WriteFile(s.path(sess.ID), data, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+// Load reads the session with the given ID. A unique ID prefix is accepted.
+func (s *SessionStore) Load(id string) (*Session, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, fmt.Errorf("invalid session id %q", id)
+	}
+
+	path := s.path(id)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		matches, _ := filepath.Glob(filepath.Join(s.dir, id+"*.json"))
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("session %q not found", id)
+		case 1:
+			path = matches[0]
+		default:
+			return nil, fmt.Errorf("session id %q is ambiguous", id)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &sess, nil
+}
+
+// List returns all stored sessions, most recently updated first.
+func (s *SessionStore) List() ([]*Session, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(paths))
+	for _, path := range paths {
+		sess, err := s.Load(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *SessionStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// estimateTokens approximates the token count of a conversation at four
+// characters per token.
+func estimateTokens(summary string, turns []Message) int {
+	chars := len(summary)
+	for _, t := range turns {
+		chars += len(t.Content)
+		for _, tc := range t.ToolCalls {
+			chars += len(tc.Name) + len(encodeJSON(tc.Args))
+		}
+	}
+	return chars / 4
+}
+
+// trimPoint returns how many leading turns to drop so that the remaining
+// history fits in half the budget. It only cuts before a user turn so that
+// tool calls stay paired with their results, and always keeps the last turn.
+func trimPoint(turns []Message, budget int) int {
+	cut := 0
+	for i := 1; i < len(turns); i++ {
+		if turns[i].Role != RoleUser {
+			continue
+		}
+		cut = i
+		if estimateTokens("", turns[i:]) <= budget/2 {
+			break
+		}
+	}
+	return cut
+}
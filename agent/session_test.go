@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+func TestSessionStore(t *testing.T) {
+	store, err := NewSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSessionStore failed: %v", err)
+	}
+
+	sess := NewSession()
+	sess.Turns = []Message{
+		{Role: RoleUser, Content: "swap 1 eth"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Name: "swap", Args: map[string]any{"amount": "1"}}}},
+		{Role: RoleTool, ToolCallID: "call_1", Name: "swap", Content: `{"output":"ok"}`},
+		{Role: RoleAssistant, Content: "Swapped."},
+	}
+	if err := store.Save(sess); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Load by full ID and by unique prefix
+	loaded, err := store.Load(sess.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Turns) != 4 || loaded.Turns[1].ToolCalls[0].Args["amount"] != "1" {
+		t.Errorf("Unexpected turns after load: %+v", loaded.Turns)
+	}
+	if _, err := store.Load(sess.ID[:12]); err != nil {
+		t.Errorf("Load by prefix failed: %v", err)
+	}
+
+	if _, err := store.Load("../config"); err == nil {
+		t.Error("Expected invalid session id to fail")
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Expected 1 session, got %d (%v)", len(list), err)
+	}
+	if list[0].Title() != "swap 1 eth" {
+		t.Errorf("Expected title 'swap 1 eth', got '%s'", list[0].Title())
+	}
+}
+
+func TestSessionTitle(t *testing.T) {
+	sess := NewSession()
+	sess.Turns = []Message{{Role: RoleUser, Content: strings.Repeat("đổi 1 eth ", 10)}}
+	title := sess.Title()
+	if !utf8.ValidString(title) || utf8.RuneCountInString(title) != 48 {
+		t.Errorf("Expected a 45-rune title and an ellipsis, got %q", title)
+	}
+}
+
+func TestSessionHistoryAndCompaction(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"` + strings.Repeat("a", 400) + `"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"second answer"}]}}]}`,
+		// Summary of the trimmed first exchange
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"User asked a question."}]}}]}`,
+	}}
+	a := newTestAgent(t, fake)
+	store, _ := NewSessionStore(t.TempDir())
+	a.Sessions = store
+	a.session = NewSession()
+	a.TokenBudget = 100
+
+	ctx := context.Background()
+	a.processMessage(ctx, "first question")
+	a.processMessage(ctx, "second question")
+
+	// The second request replays the first exchange
+	if got := len(fake.requests[1]["contents"].([]any)); got != 3 {
+		t.Errorf("Expected 3 contents in second request, got %d", got)
+	}
+
+	// The first exchange was folded into the summary
+	if a.session.Summary != "User asked a question." {
+		t.Errorf("Expected summary, got '%s'", a.session.Summary)
+	}
+	if len(a.session.Turns) != 2 || a.session.Turns[0].Content != "second question" {
+		t.Errorf("Unexpected turns after compaction: %+v", a.session.Turns)
+	}
+
+	saved, err := store.Load(a.session.ID)
+	if err != nil {
+		t.Fatalf("Session was not saved: %v", err)
+	}
+	if saved.Summary != a.session.Summary {
+		t.Errorf("Expected saved summary '%s', got '%s'", a.session.Summary, saved.Summary)
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	a := &Agent{Hub: bus.NewHub(), session: NewSession()}
+	store, _ := NewSessionStore(t.TempDir())
+	a.Sessions = store
+
+	first := a.session
+	first.Turns = []Message{{Role: RoleUser, Content: "hello"}}
+
+	a.handleCommand("/new")
+	if a.session == first {
+		t.Fatal("Expected /new to start a new session")
+	}
+	drain(a.Hub)
+
+	a.handleCommand("/sessions")
+	if ev := <-a.Hub.Outbound; !strings.Contains(ev.Payload.(bus.ResponsePayload).Text, first.ID) {
+		t.Errorf("Expected session list to contain %s, got '%v'", first.ID, ev.Payload)
+	}
+
+	a.handleCommand("/resume " + first.ID)
+	drain(a.Hub)
+	if a.session.ID != first.ID || len(a.session.Turns) != 1 {
+		t.Errorf("Expected to resume %s, got %s", first.ID, a.session.ID)
+	}
+
+	a.handleCommand("/bogus")
+	if ev := <-a.Hub.Outbound; ev.Type != bus.EventError {
+		t.Errorf("Expected error for unknown command, got '%s'", ev.Type)
+	}
+}
+
+// drain discards all buffered outbound events.
+func drain(h *bus.Hub) {
+	for len(h.Outbound) > 0 {
+		<-h.Outbound
+	}
+}
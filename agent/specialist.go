@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SkillsSpecialist is the name of the specialist that calls the installed
+// skills no other specialist takes.
+const SkillsSpecialist = "skills"
+
+// Specialist is a sub-agent with its own system prompt and tool subset.
+// The manager routes a request to exactly one specialist.
+type Specialist struct {
+	Name        string
+	Description string
+	Prompt      string
+	// Tools lists the names of the tools the specialist may call.
+	Tools []string
+}
+
+// ToolName returns the name of the manager tool that routes to the specialist.
+func (s Specialist) ToolName() string {
+	return "call_" + s.Name + "_specialist"
+}
+
+// SpecialistRegistry holds the specialists available to the manager.
+type SpecialistRegistry struct {
+	specialists map[string]Specialist
+	order       []string
+}
+
+// NewSpecialistRegistry creates an empty registry.
+func NewSpecialistRegistry() *SpecialistRegistry {
+	return &SpecialistRegistry{
+		specialists: make(map[string]Specialist),
+	}
+}
+
+// Register adds a specialist to the registry.
+func (r *SpecialistRegistry) Register(s Specialist) error {
+	if s.Name == "" {
+		return fmt.Errorf("specialist name is empty")
+	}
+	if _, exists := r.specialists[s.Name]; exists {
+		return fmt.Errorf("specialist %q already registered", s.Name)
+	}
+	r.specialists[s.Name] = s
+	r.order = append(r.order, s.Name)
+	return nil
+}
+
+// AddTool lets the named specialist call t. When no specialist has that
+// name, or it is empty, t goes to the skills specialist, which is registered
+// on first use and describes its tools to the manager.
+func (r *SpecialistRegistry) AddTool(specialist string, t Tool) {
+	s, ok := r.specialists[specialist]
+	if !ok {
+		if s, ok = r.specialists[SkillsSpecialist]; !ok {
+			s = Specialist{
+				Name:        SkillsSpecialist,
+				Description: "Installed skills:",
+				Prompt: `You are the LucciBot skills specialist. You run the skills the user installed.
+Call the skill that fits the task and report its result; never invent one.`,
+			}
+			r.Register(s)
+		}
+	}
+	if slices.Contains(s.Tools, t.Name) {
+		return
+	}
+	if s.Name == SkillsSpecialist {
+		sep := " "
+		if len(s.Tools) > 0 {
+			sep = "; "
+		}
+		s.Description = strings.TrimSuffix(s.Description, ".") + sep + t.Name + " (" + strings.TrimSuffix(t.Description, ".") + ")."
+	}
+	// Copy the tool list, which may be shared with the caller of Register.
+	s.Tools = append(slices.Clip(s.Tools), t.Name)
+	r.specialists[s.Name] = s
+}
+
+// Get returns the specialist with the given name.
+func (r *SpecialistRegistry) Get(name string) (Specialist, bool) {
+	s, ok := r.specialists[name]
+	return s, ok
+}
+
+// List returns the specialists in registration order.
+func (r *SpecialistRegistry) List() []Specialist {
+	list := make([]Specialist, 0, len(r.order))
+	for _, name := range r.order {
+		list = append(list, r.specialists[name])
+	}
+	return list
+}
+
+// Len returns the number of registered specialists.
+func (r *SpecialistRegistry) Len() int {
+	return len(r.order)
+}
+
+// byToolName resolves a manager tool name back to its specialist.
+func (r *SpecialistRegistry) byToolName(toolName string) (Specialist, bool) {
+	name := strings.TrimSuffix(strings.TrimPrefix(toolName, "call_"), "_specialist")
+	return r.Get(name)
+}
+
+// managerTools returns one call_<name>_specialist tool per specialist.
+func (r *SpecialistRegistry) managerTools() []Tool {
+	tools := make([]Tool, 0, r.Len())
+	for _, s := range r.List() {
+		tools = append(tools, Tool{
+			Name:        s.ToolName(),
+			Description: s.Description,
+			Parameters: objectSchema(map[string]string{
+				"task": "What the specialist should do, in plain language.",
+			}, "task"),
+		})
+	}
+	return tools
+}
+
+// managerPrompt builds the manager system prompt from the registered specialists.
+func (r *SpecialistRegistry) managerPrompt() string {
+	var sb strings.Builder
+	sb.WriteString("You are a crypto wallet manager. Route the user's request to ONE specialist by calling its tool:\n\n")
+	for _, s := range r.List() {
+		fmt.Fprintf(&sb, "- %s: %s\n", s.ToolName(), s.Description)
+	}
+	sb.WriteString("\nPass the specialist a self-contained task. When it answers, report the result to the user concisely.\n")
+	sb.WriteString("If the request needs no specialist, answer directly.")
+	return sb.String()
+}
+
+// DefaultSpecialists returns the Trading, Security and History specialists
+// described in docs/agent-tools.md.
+func DefaultSpecialists() *SpecialistRegistry {
+	r := NewSpecialistRegistry()
+	r.Register(Specialist{
+		Name:        "trading",
+		Description: "Balances, swaps, sends and token transfers.",
+		Prompt: `You are the LucciBot trading specialist. You handle all value transfer operations.
+Always get a swap_quote before calling swap. Never invent balances or transaction hashes; call a tool instead.`,
+		Tools: []string{"get_balance", "swap_quote", "swap", "send"},
+	})
+	r.Register(Specialist{
+		Name:        "security",
+		Description: "Approvals, revokes, transaction simulation and risk checks.",
+		Prompt: `You are the LucciBot security specialist. You handle approvals, risk assessment and transaction safety.
+Flag risky spenders and simulate transactions before recommending them.`,
+		Tools: []string{"get_approvals", "revoke_approval", "simulate_tx"},
+	})
+	r.Register(Specialist{
+		Name:        "history",
+		Description: "Past transactions, portfolio stats and PnL.",
+		Prompt: `You are the LucciBot history specialist. You handle transaction history and portfolio analytics.
+Summarize numbers clearly and include the period they cover.`,
+		Tools: []string{"get_transactions", "get_portfolio", "get_pnl"},
+	})
+	return r
+}
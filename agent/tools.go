@@ -0,0 +1,139 @@
+package agent
+
+// Tool describes a skill that the LLM is allowed to call.
+// Parameters is a JSON Schema object describing the arguments of the call.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// MergeTools returns base with the tools of extra replacing those of the
+// same name; the other tools of extra are appended.
+func MergeTools(base, extra []Tool) []Tool {
+	index := make(map[string]int, len(base))
+	merged := append([]Tool(nil), base...)
+	for i, t := range merged {
+		index[t.Name] = i
+	}
+	for _, t := range extra {
+		if i, ok := index[t.Name]; ok {
+			merged[i] = t
+			continue
+		}
+		index[t.Name] = len(merged)
+		merged = append(merged, t)
+	}
+	return merged
+}
+
+// objectSchema builds a JSON Schema object from string properties.
+func objectSchema(props map[string]string, required ...string) map[string]any {
+	properties := make(map[string]any, len(props))
+	for name, desc := range props {
+		properties[name] = map[string]any{
+			"type":        "string",
+			"description": desc,
+		}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// DefaultTools returns the skills documented in docs/agent-tools.md.
+func DefaultTools() []Tool {
+	return []Tool{
+		{
+			Name:        "get_balance",
+			Description: "Get wallet balances (native + tokens).",
+			Parameters: objectSchema(map[string]string{
+				"chain": "Chain to query, e.g. ethereum, arbitrum, base, polygon.",
+			}),
+		},
+		{
+			Name:        "swap_quote",
+			Description: "Get a quote for a token swap.",
+			Parameters: objectSchema(map[string]string{
+				"from_token": "Token to sell.",
+				"to_token":   "Token to buy.",
+				"amount":     "Amount of from_token to sell.",
+				"chain":      "Chain to swap on.",
+			}, "from_token", "to_token", "amount"),
+		},
+		{
+			Name:        "swap",
+			Description: "Execute a token swap.",
+			Parameters: objectSchema(map[string]string{
+				"from_token": "Token to sell.",
+				"to_token":   "Token to buy.",
+				"amount":     "Amount of from_token to sell.",
+				"slippage":   "Maximum slippage in percent.",
+				"chain":      "Chain to swap on.",
+			}, "from_token", "to_token", "amount"),
+		},
+		{
+			Name:        "send",
+			Description: "Send tokens to an address.",
+			Parameters: objectSchema(map[string]string{
+				"to":     "Recipient address.",
+				"token":  "Token to send.",
+				"amount": "Amount to send.",
+				"chain":  "Chain to send on.",
+			}, "to", "token", "amount"),
+		},
+		{
+			Name:        "get_approvals",
+			Description: "List all token approvals.",
+			Parameters: objectSchema(map[string]string{
+				"chain": "Chain to query.",
+			}),
+		},
+		{
+			Name:        "revoke_approval",
+			Description: "Revoke a token approval.",
+			Parameters: objectSchema(map[string]string{
+				"token":   "Approved token.",
+				"spender": "Spender to revoke.",
+				"chain":   "Chain of the approval.",
+			}, "token", "spender", "chain"),
+		},
+		{
+			Name:        "simulate_tx",
+			Description: "Simulate the effects of a transaction.",
+			Parameters: objectSchema(map[string]string{
+				"to":    "Destination address.",
+				"value": "Value in wei.",
+				"data":  "Hex encoded calldata.",
+				"chain": "Chain to simulate on.",
+			}, "to", "chain"),
+		},
+		{
+			Name:        "get_transactions",
+			Description: "Get transaction history.",
+			Parameters: objectSchema(map[string]string{
+				"chain": "Chain to query.",
+				"type":  "One of all, send, swap.",
+				"limit": "Maximum number of transactions.",
+			}),
+		},
+		{
+			Name:        "get_portfolio",
+			Description: "Get portfolio breakdown.",
+			Parameters:  objectSchema(nil),
+		},
+		{
+			Name:        "get_pnl",
+			Description: "Get profit/loss stats.",
+			Parameters: objectSchema(map[string]string{
+				"period": "One of 24h, 7d, 30d.",
+			}),
+		},
+	}
+}
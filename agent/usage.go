@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Usage is the token usage reported by a provider for one response.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// UsageTotals accumulates token usage and its cost in USD.
+type UsageTotals struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add accumulates a response's usage and cost.
+func (t *UsageTotals) Add(u Usage, cost float64) {
+	t.PromptTokens += u.PromptTokens
+	t.CompletionTokens += u.CompletionTokens
+	t.Cost += cost
+}
+
+// Tokens returns the total number of tokens.
+func (t UsageTotals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// UsageLedger persists per-day usage totals so API spend can be budgeted
+// across sessions.
+type UsageLedger struct {
+	path string
+	mu   sync.Mutex
+	// Days maps a date (YYYY-MM-DD) to the usage of that day.
+	Days map[string]*UsageTotals `json:"days"`
+}
+
+// DefaultUsagePath returns the default ledger path.
+// Usually ~/.luccibot/usage.json
+func DefaultUsagePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".luccibot", "usage.json"), nil
+}
+
+// OpenUsageLedger loads the ledger at path, starting empty if it does not exist.
+func OpenUsageLedger(path string) (*UsageLedger, error) {
+	l := &UsageLedger{path: path, Days: make(map[string]*UsageTotals)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage ledger: %w", err)
+	}
+	if l.Days == nil {
+		l.Days = make(map[string]*UsageTotals)
+	}
+	return l, nil
+}
+
+// Add records usage on the given day and saves the ledger.
+func (l *UsageLedger) Add(day time.Time, u Usage, cost float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := day.Format("2006-01-02")
+	totals, ok := l.Days[key]
+	if !ok {
+		totals = &UsageTotals{}
+		l.Days[key] = totals
+	}
+	totals.Add(u, cost)
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage ledger: %w", err)
+	}
+	if err := os.WriteFile(l.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	return nil
+}
+
+// Day returns the usage totals of the given day.
+func (l *UsageLedger) Day(day time.Time) UsageTotals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if totals, ok := l.Days[day.Format("2006-01-02")]; ok {
+		return *totals
+	}
+	return UsageTotals{}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+	"github.com/lucci-labs/luccibot/config"
+)
+
+func TestUsageAccounting(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi"}]}}],"usageMetadata":{"promptTokenCount":1000,"candidatesTokenCount":200,"thoughtsTokenCount":300}}`,
+	}}
+	a := newTestAgent(t, fake)
+	a.session = NewSession()
+	a.Prices = config.PriceTable{"gemini-test": {InputPerMillion: 1, OutputPerMillion: 10, ContextWindow: 10000}}
+
+	path := filepath.Join(t.TempDir(), "usage.json")
+	ledger, err := OpenUsageLedger(path)
+	if err != nil {
+		t.Fatalf("OpenUsageLedger failed: %v", err)
+	}
+	a.Ledger = ledger
+
+	a.processMessage(context.Background(), "hi")
+
+	var stats bus.UsageStats
+	for len(a.Hub.Outbound) > 0 {
+		if ev := <-a.Hub.Outbound; ev.Type == bus.EventUsage {
+			stats = ev.Payload.(bus.UsageStats)
+		}
+	}
+
+	// 1000 input tokens at $1/M plus 500 output tokens at $10/M
+	wantCost := 0.006
+	if stats.SessionTokens != 1500 || stats.ContextTokens != 1500 || stats.ContextWindow != 10000 {
+		t.Errorf("Unexpected usage stats: %+v", stats)
+	}
+	if diff := stats.SessionCost - wantCost; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected session cost %f, got %f", wantCost, stats.SessionCost)
+	}
+	if stats.DayCost != stats.SessionCost {
+		t.Errorf("Expected day cost %f, got %f", stats.SessionCost, stats.DayCost)
+	}
+
+	// Day totals survive a restart
+	reopened, err := OpenUsageLedger(path)
+	if err != nil {
+		t.Fatalf("OpenUsageLedger failed: %v", err)
+	}
+	if day := reopened.Day(time.Now()); day.Tokens() != 1500 {
+		t.Errorf("Expected 1500 tokens today, got %d", day.Tokens())
+	}
+}
@@ -0,0 +1,1158 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+// writeSkill creates a skill directory with the given manifest and entry script.
+func writeSkill(t *testing.T, dir, name, manifest, script string) {
+	t.Helper()
+	skillDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(skillDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(skillDir, ManifestFile), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(skillDir, "main.ts"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeBun puts a "bun" on PATH that runs the entry script with sh, so tests
+// need no JavaScript runtime.
+func fakeBun(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "bun"), []byte("#!/bin/sh\nexec sh \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+const balanceManifest = `{
+  "name": "get_balance",
+  "description": "Get wallet balances.",
+  "entry": "main.ts",
+  "parameters": {
+    "type": "object",
+    "properties": {"chain": {"type": "string", "enum": ["ethereum", "base"]}},
+    "required": ["chain"],
+    "additionalProperties": false
+  },
+  "output": "data",
+  "permissions": ["network"]
+}`
+
+func TestRegistryScan(t *testing.T) {
+	dir := t.TempDir()
+	writeSkill(t, dir, "balance", balanceManifest, "")
+	writeSkill(t, dir, "broken", `{"name": "Bad Name", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"}`, "")
+	writeSkill(t, dir, "unsigned", `{"name": "swap", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "transaction"}`, "")
+	writeSkill(t, dir, "escape", `{"name": "escape", "description": "x", "entry": "../balance/main.ts", "parameters": {"type": "object"}, "output": "data"}`, "")
+	writeSkill(t, dir, "link", `{"name": "link", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"}`, "")
+	os.Remove(filepath.Join(dir, "link", "main.ts"))
+	os.Symlink(filepath.Join(dir, "balance", "main.ts"), filepath.Join(dir, "link", "main.ts"))
+	os.MkdirAll(filepath.Join(dir, "no_manifest"), 0755)
+
+	r := NewRegistry()
+	err := r.Scan(dir)
+	if err == nil || !strings.Contains(err.Error(), "Bad Name") || !strings.Contains(err.Error(), `"sign" permission`) {
+		t.Errorf("Expected errors for both invalid manifests, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "entry of skill escape leaves its directory") || !strings.Contains(err.Error(), "entry of skill link leaves its directory") {
+		t.Errorf("Expected entries leaving their directory to be refused, got %v", err)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("Expected 1 valid skill, got %d", r.Len())
+	}
+	m, ok := r.Get("get_balance")
+	if !ok || m.Dir != filepath.Join(dir, "balance") || !m.HasPermission(PermissionNetwork) {
+		t.Errorf("Unexpected manifest: %+v", m)
+	}
+
+	// A missing skills directory holds no skills
+	if err := NewRegistry().Scan(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("Expected no error for a missing directory, got %v", err)
+	}
+}
+
+func TestValidateArgs(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"to":       map[string]any{"type": "string", "pattern": "^0x[0-9a-fA-F]{40}$"},
+			"amount":   map[string]any{"type": "number", "minimum": 0},
+			"decimals": map[string]any{"type": "integer"},
+			"tags":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		"required": []any{"to"},
+	}
+	to := "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+	tests := []struct {
+		args map[string]any
+		err  string
+	}{
+		{map[string]any{"to": to, "amount": 1.5, "decimals": float64(18), "tags": []any{"a"}}, ""},
+		{map[string]any{"amount": 1.0}, `missing required property "to"`},
+		{map[string]any{"to": "alice"}, "does not match"},
+		{map[string]any{"to": to, "amount": -1.0}, "must be >= 0"},
+		{map[string]any{"to": to, "amount": "1"}, "expected number, got string"},
+		{map[string]any{"to": to, "decimals": 1.5}, "expected integer"},
+		{map[string]any{"to": to, "tags": []any{1.0}}, "tags[0]: expected string"},
+	}
+	for _, tt := range tests {
+		err := ValidateArgs(schema, tt.args)
+		if tt.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.args, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.err, err)
+		}
+	}
+}
+
+func TestExecuteSkill(t *testing.T) {
+	fakeBun(t)
+	dir := t.TempDir()
+	writeSkill(t, dir, "balance", balanceManifest, `
+cat > "$(dirname "$0")/request.json"
+echo '{"version":1,"type":"progress","message":"querying"}'
+echo '{"version":1,"type":"info","data":{"balance":"1"}}'
+`)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+
+	// Events are not under test
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+
+	run := func(args map[string]any) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: "get_balance", Args: args, ResultChan: results}
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+
+	// Data skills answer without going through the vault
+	r := run(map[string]any{"chain": "base"})
+	if r.Error != nil || string(r.Output) != `{"balance":"1"}` {
+		t.Errorf("Unexpected result: %q, %v", r.Output, r.Error)
+	}
+	if len(h.SignReq) != 0 {
+		t.Error("Expected no sign request for a data skill")
+	}
+
+	// The skill got the request envelope on stdin
+	data, _ := os.ReadFile(filepath.Join(dir, "balance", "request.json"))
+	var req SkillRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("Invalid request %q: %v", data, err)
+	}
+	if req.Version != ProtocolVersion || req.Action != "get_balance" || req.Chain != "base" || req.Params["chain"] != "base" || req.RequestID == "" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+
+	// Arguments are validated against the manifest
+	if r := run(map[string]any{"chain": "solana"}); r.Error == nil || !strings.Contains(r.Error.Error(), "must be one of") {
+		t.Errorf("Expected enum validation error, got %v", r.Error)
+	}
+	if r := run(map[string]any{"chain": "base", "extra": true}); r.Error == nil || !strings.Contains(r.Error.Error(), "unexpected property") {
+		t.Errorf("Expected unexpected property error, got %v", r.Error)
+	}
+
+	// Unknown skills are refused
+	results := make(chan bus.ActionResult, 1)
+	h.ActionReq <- bus.Action{SkillName: "rm_rf", ResultChan: results}
+	if r := <-results; r.Error == nil || !strings.Contains(r.Error.Error(), "not installed") {
+		t.Errorf("Expected unknown skill error, got %v", r.Error)
+	}
+}
+
+func TestSkillProtocol(t *testing.T) {
+	fakeBun(t)
+	dir := t.TempDir()
+	manifest := func(name, output string) string {
+		return `{"name": "` + name + `", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "` + output + `", "permissions": ["sign"]}`
+	}
+	writeSkill(t, dir, "send", manifest("send", "transaction"), `echo '{"version":1,"type":"tx_request","chain":"base","tx":{"to":"0x1"}}'`)
+	writeSkill(t, dir, "quote", manifest("quote", "data"), `echo '{"version":1,"type":"tx_request","tx":{"to":"0x1"}}'`)
+	writeSkill(t, dir, "failing", manifest("failing", "data"), `echo '{"version":1,"type":"error","message":"insufficient funds"}'; exit 1`)
+	writeSkill(t, dir, "legacy", manifest("legacy", "transaction"), `echo '{"to":"0x1"}'`)
+	writeSkill(t, dir, "future", manifest("future", "data"), `echo '{"version":2,"type":"info","data":{}}'`)
+	writeSkill(t, dir, "silent", manifest("silent", "data"), `echo 'boom' >&2; exit 3`)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+
+	// Events are not under test
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+
+	run := func(skill string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{SkillName: skill, ResultChan: results}
+		return <-results
+	}
+
+	// tx_request goes to the vault
+	go func() {
+		req := <-h.SignReq
+		if string(req.TxData) != `{"to":"0x1"}` {
+			t.Errorf("Unexpected tx data %q", req.TxData)
+		}
+		req.ResponseChan <- bus.SignResponse{Signature: []byte("0xsig")}
+	}()
+	if r := run("send"); r.Error != nil || string(r.Signature) != "0xsig" {
+		t.Errorf("Expected signed result, got %+v", r)
+	}
+
+	tests := []struct {
+		skill string
+		err   string
+	}{
+		{"quote", "not allowed to request signatures"},
+		{"failing", "insufficient funds"},
+		{"legacy", "invalid output"},
+		{"future", "unsupported protocol version 2"},
+		{"silent", "boom"},
+	}
+	for _, tt := range tests {
+		if r := run(tt.skill); r.Error == nil || !strings.Contains(r.Error.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.skill, tt.err, r.Error)
+		}
+	}
+}
+
+func TestSkillTimeoutAndCancel(t *testing.T) {
+	fakeBun(t)
+	dir := t.TempDir()
+	manifest := func(name string, timeout int) string {
+		return `{"name": "` + name + `", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data", "timeout": ` + strconv.Itoa(timeout) + `}`
+	}
+	// The child sleep holds stdout open, so the skill only ends once its
+	// whole process group is killed.
+	hang := `echo 'still working' >&2; sleep 30 & wait`
+	writeSkill(t, dir, "slow", manifest("slow", 1), hang)
+	writeSkill(t, dir, "stuck", manifest("stuck", 0), hang)
+	writeSkill(t, dir, "noisy", manifest("noisy", 0), `yes 'spam' | head -c 100000 >&2; echo 'last words' >&2; exit 1`)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+
+	run := func(skill string) (string, <-chan bus.ActionResult) {
+		results := make(chan bus.ActionResult, 1)
+		id := bus.NewRequestID()
+		h.ActionReq <- bus.Action{RequestID: id, SkillName: skill, ResultChan: results}
+		return id, results
+	}
+	wait := func(results <-chan bus.ActionResult) bus.ActionResult {
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+
+	// The manifest timeout kills the skill and its children
+	_, results := run("slow")
+	r := wait(results)
+	if r.Error == nil || !strings.Contains(r.Error.Error(), "timed out after 1s: still working") {
+		t.Errorf("Expected timeout error with stderr, got %v", r.Error)
+	}
+
+	// SkillCancel kills a running skill
+	id, results := run("stuck")
+	for {
+		b.mu.Lock()
+		_, running := b.running[id]
+		b.mu.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.SkillCancel <- ""
+	if r := wait(results); r.Error == nil || !strings.Contains(r.Error.Error(), "skill stuck cancelled") {
+		t.Errorf("Expected cancellation error, got %v", r.Error)
+	}
+	if b.Cancel("") {
+		t.Error("Expected no running skill after cancellation")
+	}
+
+	// Only the end of stderr is kept
+	_, results = run("noisy")
+	r = wait(results)
+	if r.Error == nil || !strings.HasSuffix(r.Error.Error(), "last words") || len(r.Error.Error()) > maxStderr+100 {
+		t.Errorf("Expected bounded stderr ending with the last line, got %d bytes", len(r.Error.Error()))
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	fakeBun(t)
+	dir := t.TempDir()
+	gate := filepath.Join(dir, "gate")
+	writeSkill(t, dir, "slow", `{"name": "slow", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data", "concurrency": 1}`,
+		`while [ ! -f "`+gate+`" ]; do sleep 0.05; done; echo '{"version":1,"type":"info","data":"slow"}'`)
+	writeSkill(t, dir, "fast", `{"name": "fast", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"}`,
+		`echo '{"version":1,"type":"info","data":"fast"}'`)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+
+	statuses := make(chan bus.SkillStatusPayload, 100)
+	go func() {
+		for ev := range h.Outbound {
+			if p, ok := ev.Payload.(bus.SkillStatusPayload); ok {
+				statuses <- p
+			}
+		}
+	}()
+	waitStatus := func(id string, want bus.SkillStatus) bus.SkillStatusPayload {
+		for {
+			select {
+			case p := <-statuses:
+				if p.ActionID == id && p.Status == want {
+					return p
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for %s to be %s", id, want)
+			}
+		}
+	}
+
+	run := func(skill string) (string, <-chan bus.ActionResult) {
+		results := make(chan bus.ActionResult, 1)
+		id := bus.NewRequestID()
+		h.ActionReq <- bus.Action{RequestID: id, SkillName: skill, ResultChan: results}
+		return id, results
+	}
+
+	first, firstResult := run("slow")
+	waitStatus(first, bus.SkillRunning)
+	second, secondResult := run("slow")
+	if p := waitStatus(second, bus.SkillQueued); p.Queued != 1 || p.Running != 1 {
+		t.Errorf("Expected 1 queued and 1 running, got %+v", p)
+	}
+
+	// A slow skill does not hold up other skills
+	_, fastResult := run("fast")
+	select {
+	case r := <-fastResult:
+		if string(r.Output) != `"fast"` {
+			t.Errorf("Unexpected fast result %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fast skill was blocked by the slow one")
+	}
+	select {
+	case <-secondResult:
+		t.Fatal("Second run of slow started despite its concurrency limit")
+	default:
+	}
+
+	os.WriteFile(gate, nil, 0644)
+	waitStatus(first, bus.SkillFinished)
+	waitStatus(second, bus.SkillRunning)
+	if p := waitStatus(second, bus.SkillFinished); p.Queued != 0 || p.Running != 0 {
+		t.Errorf("Expected an idle pool, got %+v", p)
+	}
+	for _, results := range []<-chan bus.ActionResult{firstResult, secondResult} {
+		if r := <-results; r.Error != nil || string(r.Output) != `"slow"` {
+			t.Errorf("Unexpected slow result %+v", r)
+		}
+	}
+}
+
+func TestPoolLimits(t *testing.T) {
+	b := NewBridge(bus.NewHub(), "")
+	b.MaxConcurrent = 2
+	swap := Manifest{Name: "swap", Output: OutputTransaction}
+	send := Manifest{Name: "send", Output: OutputTransaction}
+	quote := Manifest{Name: "quote", Output: OutputData}
+
+	b.acquire(swap, 1)
+	if b.fits(send) {
+		t.Error("Expected only one transaction skill at a time")
+	}
+	if !b.fits(quote) {
+		t.Error("Expected a data skill to fit next to a transaction skill")
+	}
+	b.acquire(quote, 1)
+	if b.fits(quote) {
+		t.Error("Expected the global limit to apply")
+	}
+	b.acquire(swap, -1)
+	if !b.fits(send) || b.bySkill["swap"] != 0 {
+		t.Errorf("Expected freed slots, got %v", b.bySkill)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	fakeBun(t)
+	t.Setenv("LUCCI_SECRET", "hunter2")
+	t.Setenv("LUCCI_RPC", "https://rpc.example")
+	dir := t.TempDir()
+	manifest := func(name, extra string) string {
+		return `{"name": "` + name + `", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"` + extra + `}`
+	}
+	writeSkill(t, dir, "env", manifest("env", `, "env": ["LUCCI_RPC"], "limits": {"files": 64}`),
+		`printf '{"version":1,"type":"info","data":{"secret":"%s","rpc":"%s","home":"%s","pwd":"%s","files":"%s"}}\n' "$LUCCI_SECRET" "$LUCCI_RPC" "$HOME" "$(pwd)" "$(ulimit -n)"`)
+	writeSkill(t, dir, "spin", manifest("spin", `, "limits": {"cpu": 1}`), `while :; do :; done`)
+	writeSkill(t, dir, "net", manifest("net", ""), `printf '{"version":1,"type":"info","data":"%s"}\n' "$(tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' ' | tr '\n' ' ')"`)
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	os.WriteFile(keyFile, []byte("private"), 0600)
+	t.Setenv("LUCCI_KEYFILE", keyFile)
+	writeSkill(t, dir, "fs", manifest("fs", `, "env": ["LUCCI_KEYFILE"]`), `
+key=$(cat "$LUCCI_KEYFILE" 2>/dev/null || echo denied)
+home=$(echo ok > "$HOME/f" && cat "$HOME/f")
+own=$(touch "$(dirname "$0")/f" 2>/dev/null && echo written || echo denied)
+proc=$(cat /proc/`+strconv.Itoa(os.Getpid())+`/environ >/dev/null 2>&1 && echo read || echo denied)
+[ -e /proc/`+strconv.Itoa(os.Getpid())+` ] && proc=visible
+printf '{"version":1,"type":"info","data":{"key":"%s","home":"%s","own":"%s","proc":"%s"}}\n' "$key" "$home" "$own" "$proc"`)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+
+	run := func(skill string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: skill, ResultChan: results}
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+
+	// Only allowlisted variables get through, and the skill runs in a
+	// private directory that is removed afterwards
+	r := run("env")
+	var got map[string]string
+	if err := json.Unmarshal(r.Output, &got); err != nil {
+		t.Fatalf("Unexpected result %q, %v", r.Output, r.Error)
+	}
+	if got["secret"] != "" || got["rpc"] != "https://rpc.example" || got["files"] != "64" {
+		t.Errorf("Unexpected environment: %v", got)
+	}
+	if got["pwd"] != got["home"] || !strings.Contains(got["pwd"], "luccibot-env-") {
+		t.Errorf("Expected a private working directory, got %v", got)
+	}
+	if _, err := os.Stat(got["pwd"]); !os.IsNotExist(err) {
+		t.Errorf("Expected the working directory to be removed, got %v", err)
+	}
+
+	// Limit violations are reported as skill errors
+	if r := run("spin"); r.Error == nil || !strings.Contains(r.Error.Error(), "exceeded its CPU limit of 1s") {
+		t.Errorf("Expected CPU limit error, got %v", r.Error)
+	}
+
+	// Isolated skills without the network permission see no interfaces but lo
+	b.Isolate = true
+	r = run("net")
+	if r.Error != nil && strings.Contains(r.Error.Error(), "operation not permitted") {
+		t.Skipf("User namespaces are not available: %v", r.Error)
+	}
+	if r.Error != nil || strings.TrimSpace(string(r.Output)) != `"lo "` {
+		t.Errorf("Expected only the loopback interface, got %q, %v", r.Output, r.Error)
+	}
+
+	// Isolated skills only reach their own directory, read-only, and their
+	// temp directory, and cannot see luccibot in /proc
+	r = run("fs")
+	if r.Error != nil && strings.Contains(r.Error.Error(), "Landlock is not available") {
+		t.Skipf("Landlock is not available: %v", r.Error)
+	}
+	got = nil
+	json.Unmarshal(r.Output, &got)
+	if r.Error != nil || got["key"] != "denied" || got["own"] != "denied" || got["home"] != "ok" || got["proc"] != "denied" {
+		t.Errorf("Expected the filesystem to be confined, got %s, %v", r.Output, r.Error)
+	}
+}
+
+func TestManifestEnv(t *testing.T) {
+	m := Manifest{Name: "x", Description: "x", Entry: "main.ts", Parameters: map[string]any{"type": "object"}, Output: OutputData}
+	for _, tt := range []struct {
+		env []string
+		err string
+	}{
+		{[]string{"RPC_URL"}, ""},
+		{[]string{"OPENAI_API_KEY"}, "may not receive OPENAI_API_KEY"},
+		{[]string{"A=B"}, "invalid environment variable"},
+	} {
+		m.Env = tt.env
+		err := m.Validate()
+		if (tt.err == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%v: expected error %q, got %v", tt.env, tt.err, err)
+		}
+	}
+}
+
+func TestRuntimes(t *testing.T) {
+	for entry, want := range map[string]string{"main.ts": RuntimeBun, "index.mjs": RuntimeBun, "main.py": RuntimePython, "run": RuntimeExec} {
+		if got := (Manifest{Entry: entry}).RuntimeName(); got != want {
+			t.Errorf("%s: expected runtime %s, got %s", entry, want, got)
+		}
+	}
+	if got := (Manifest{Entry: "main.ts", Runtime: RuntimeDeno}).RuntimeName(); got != RuntimeDeno {
+		t.Errorf("Expected the manifest runtime to win, got %s", got)
+	}
+
+	deno := denoCommand(Manifest{Permissions: []string{PermissionNetwork}, Env: []string{"RPC_URL"}}, "/skills/x/main.ts")
+	if got := strings.Join(deno, " "); got != "deno run --quiet --no-prompt --allow-net --allow-env=RPC_URL /skills/x/main.ts" {
+		t.Errorf("Unexpected deno command %q", got)
+	}
+
+	dir := t.TempDir()
+	manifest := func(name, entry, runtime string) string {
+		return `{"name": "` + name + `", "description": "x", "entry": "` + entry + `", "runtime": "` + runtime + `", "parameters": {"type": "object"}, "output": "data"}`
+	}
+	os.MkdirAll(filepath.Join(dir, "py"), 0755)
+	os.WriteFile(filepath.Join(dir, "py", ManifestFile), []byte(manifest("py", "main.py", "")), 0644)
+	os.WriteFile(filepath.Join(dir, "py", "main.py"), []byte(`import json, sys
+req = json.load(sys.stdin)
+print(json.dumps({"version": 1, "type": "info", "data": {"chain": req["chain"]}}))
+`), 0644)
+	writeSkill(t, dir, "custom", manifest("custom", "main.ts", "sh"), `echo '{"version":1,"type":"info","data":"custom"}'`)
+	writeSkill(t, dir, "missing", manifest("missing", "main.ts", "ruby"), "")
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	b.SetRuntime("sh", RuntimeFunc(func(m Manifest, entry string) []string { return []string{"sh", entry} }))
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+
+	run := func(skill string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: skill, ResultChan: results}
+		return <-results
+	}
+
+	if r := run("custom"); r.Error != nil || string(r.Output) != `"custom"` {
+		t.Errorf("Unexpected result of the custom runtime: %q, %v", r.Output, r.Error)
+	}
+	if r := run("missing"); r.Error == nil || !strings.Contains(r.Error.Error(), `unknown runtime "ruby"`) {
+		t.Errorf("Expected unknown runtime error, got %v", r.Error)
+	}
+	if _, err := exec.LookPath("python3"); err == nil {
+		if r := run("py"); r.Error != nil || string(r.Output) != `{"chain": "ethereum"}` {
+			t.Errorf("Unexpected result of the python skill: %q, %v", r.Output, r.Error)
+		}
+	}
+}
+
+func TestGoSkill(t *testing.T) {
+	h := bus.NewHub()
+	b := NewBridge(h, t.TempDir())
+	manifest := func(name string, output OutputKind, timeout int) Manifest {
+		perms := []string{}
+		if output == OutputTransaction {
+			perms = append(perms, PermissionSign)
+		}
+		return Manifest{Name: name, Description: "x", Parameters: map[string]any{"type": "object"}, Output: output, Permissions: perms, Timeout: timeout}
+	}
+	register := func(m Manifest, f GoSkillFunc) {
+		if err := b.RegisterGo(m, f); err != nil {
+			t.Fatalf("RegisterGo failed: %v", err)
+		}
+	}
+	register(manifest("quote", OutputData, 0), func(ctx context.Context, req SkillRequest, progress func(string)) (SkillMessage, error) {
+		progress("pricing")
+		return SkillMessage{Type: MessageInfo, Data: json.RawMessage(`{"chain":"` + req.Chain + `"}`)}, nil
+	})
+	register(manifest("sneaky", OutputData, 0), func(ctx context.Context, req SkillRequest, progress func(string)) (SkillMessage, error) {
+		return SkillMessage{Type: MessageTxRequest, Tx: json.RawMessage(`{}`)}, nil
+	})
+	register(manifest("hang", OutputData, 1), func(ctx context.Context, req SkillRequest, progress func(string)) (SkillMessage, error) {
+		<-ctx.Done()
+		return SkillMessage{}, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	logs := make(chan string, 100)
+	go func() {
+		for ev := range h.Outbound {
+			if p, ok := ev.Payload.(bus.LogPayload); ok {
+				logs <- p.Message
+			}
+		}
+	}()
+
+	run := func(skill string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: skill, Args: map[string]any{"chain": "base"}, ResultChan: results}
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+
+	if r := run("quote"); r.Error != nil || string(r.Output) != `{"chain":"base"}` {
+		t.Errorf("Unexpected result: %q, %v", r.Output, r.Error)
+	}
+	for msg := ""; msg != "[quote] pricing"; {
+		select {
+		case msg = <-logs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the progress step as a log event")
+		}
+	}
+	if r := run("sneaky"); r.Error == nil || !strings.Contains(r.Error.Error(), "not allowed to request signatures") {
+		t.Errorf("Expected the data skill to be refused signing, got %v", r.Error)
+	}
+	if r := run("hang"); r.Error == nil || !strings.Contains(r.Error.Error(), "timed out after 1s") {
+		t.Errorf("Expected timeout, got %v", r.Error)
+	}
+}
+
+func TestWasmSkill(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the WASM skill is slow")
+	}
+	dir := t.TempDir()
+	module := filepath.Join(t.TempDir(), "main.wasm")
+	build := exec.Command("go", "build", "-o", module, "./testdata/wasmskill")
+	build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("cannot build the WASM skill: %v\n%s", err, out)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	b.SkillConfig = map[string]map[string]string{"wasm": {"api_key": "secret"}}
+	manifest := func(name string, output OutputKind, timeout int, perms ...string) {
+		data, err := json.Marshal(Manifest{
+			Name:        name,
+			Description: "WASM test skill.",
+			Entry:       "main.wasm",
+			Parameters:  map[string]any{"type": "object"},
+			Output:      output,
+			Permissions: perms,
+			Hosts:       []string{"127.0.0.1"},
+			Timeout:     timeout,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeSkill(t, dir, name, string(data), "")
+		if err := os.Link(module, filepath.Join(dir, name, "main.wasm")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest("wasm", OutputTransaction, 0, PermissionNetwork, PermissionConfig, PermissionSign)
+	manifest("spin", OutputTransaction, 1, PermissionNetwork, PermissionConfig, PermissionSign)
+	manifest("ungranted", OutputData, 0, PermissionNetwork, PermissionConfig)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+	go func() {
+		for req := range h.SignReq {
+			req.ResponseChan <- bus.SignResponse{RequestID: req.RequestID, Signature: []byte("0xsig:" + string(req.TxData))}
+		}
+	}()
+
+	run := func(skill, mode, arg string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: skill, Args: map[string]any{"mode": mode, "arg": arg}, ResultChan: results}
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+	expect := func(r bus.ActionResult, want string) {
+		t.Helper()
+		if r.Error != nil || !strings.Contains(string(r.Output), want) {
+			t.Errorf("Expected output containing %s, got %s, %v", want, r.Output, r.Error)
+		}
+	}
+
+	get := func(url string) string {
+		data, _ := json.Marshal(map[string]string{"url": url})
+		return string(data)
+	}
+	expect(run("wasm", "http", get(srv.URL+"/ping")), `"body":"pong /ping"`)
+	expect(run("wasm", "http", get(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))), `is not allowed`)
+	expect(run("wasm", "config", "api_key"), `{"value":"secret"}`)
+	expect(run("wasm", "config", "missing"), `is not set`)
+	expect(run("wasm", "sign", `{"to":"0x1"}`), `"signature":"0xsig:{\"to\":\"0x1\"}"`)
+
+	if r := run("ungranted", "config", "api_key"); r.Error == nil || !strings.Contains(r.Error.Error(), "which its manifest does not grant") {
+		t.Errorf("Expected ungranted imports to be refused, got %v", r.Error)
+	}
+	if r := run("spin", "spin", ""); r.Error == nil || !strings.Contains(r.Error.Error(), "timed out after 1s") {
+		t.Errorf("Expected timeout, got %v", r.Error)
+	}
+}
+
+const daemonScript = `
+while IFS= read -r line; do
+  id=$(printf '%s\n' "$line" | sed -n 's/.*"id":\([0-9]*\).*/\1/p')
+  rid=$(printf '%s\n' "$line" | sed -n 's/.*"request_id":"\([^"]*\)".*/\1/p')
+  ok="{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{\"version\":1,\"type\":\"info\",\"data\":{\"pid\":$$}}}"
+  case "$line" in
+  *'"method":"ping"'*) echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{}}" ;;
+  *'"method":"shutdown"'*) echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{}}"; echo stopped >> "$DAEMON_MARK"; exit 0 ;;
+  *'"method":"cancel"'*) echo "cancelled $rid" >> "$DAEMON_MARK" ;;
+  *'"mode":"crash"'*) exit 3 ;;
+  *'"mode":"freeze"'*) echo "$ok"; exec sleep 1000 ;;
+  *'"mode":"slow"'*) sleep 0.5; echo "$ok" ;;
+  *'"mode":"ignore"'*) ;;
+  *'"mode":"fail"'*) echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"error\":{\"code\":1,\"message\":\"no route\"}}" ;;
+  *)
+    echo "{\"jsonrpc\":\"2.0\",\"method\":\"progress\",\"params\":{\"request_id\":\"$rid\",\"message\":\"warm\"}}"
+    echo "$ok" ;;
+  esac
+done
+`
+
+func TestDaemonSkill(t *testing.T) {
+	fakeBun(t)
+	for _, v := range []*time.Duration{&daemonHealthInterval, &daemonHealthTimeout, &daemonMinBackoff} {
+		old := *v
+		*v = 100 * time.Millisecond
+		t.Cleanup(func() { *v = old })
+	}
+	mark := filepath.Join(t.TempDir(), "mark")
+	t.Setenv("DAEMON_MARK", mark)
+
+	dir := t.TempDir()
+	writeSkill(t, dir, "daemon", `{"name": "daemon", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data", "daemon": true, "timeout": 1, "env": ["DAEMON_MARK"]}`, daemonScript)
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	logs := make(chan string, 100)
+	go func() {
+		for ev := range h.Outbound {
+			if p, ok := ev.Payload.(bus.LogPayload); ok {
+				select {
+				case logs <- p.Message:
+				default:
+				}
+			}
+		}
+	}()
+
+	run := func(mode string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: "daemon", Args: map[string]any{"mode": mode}, ResultChan: results}
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+	pid := func(r bus.ActionResult) int {
+		t.Helper()
+		var out struct{ PID int }
+		if r.Error != nil || json.Unmarshal(r.Output, &out) != nil || out.PID == 0 {
+			t.Fatalf("Unexpected result: %s, %v", r.Output, r.Error)
+		}
+		return out.PID
+	}
+
+	first := pid(run("normal"))
+	if again := pid(run("normal")); again != first {
+		t.Errorf("Expected the daemon to serve both runs, got pids %d and %d", first, again)
+	}
+	for msg := ""; msg != "[daemon] warm"; {
+		select {
+		case msg = <-logs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the progress notification as a log event")
+		}
+	}
+	if r := run("fail"); r.Error == nil || !strings.Contains(r.Error.Error(), "skill daemon failed: no route") {
+		t.Errorf("Expected the JSON-RPC error, got %v", r.Error)
+	}
+	if r := run("ignore"); r.Error == nil || !strings.Contains(r.Error.Error(), "timed out after 1s") {
+		t.Errorf("Expected timeout, got %v", r.Error)
+	}
+
+	// A crashed daemon is restarted.
+	if r := run("crash"); r.Error == nil || !strings.Contains(r.Error.Error(), "daemon exited") {
+		t.Errorf("Expected the crash to fail the run, got %v", r.Error)
+	}
+	restarted := pid(run("normal"))
+	if restarted == first {
+		t.Error("Expected a new daemon after the crash")
+	}
+
+	// A run longer than the health timeout holds up the pings of a daemon
+	// serving one request at a time; it must not be killed for it.
+	if slow := pid(run("slow")); slow != restarted {
+		t.Errorf("Expected the slow run to complete on daemon %d, got %d", restarted, slow)
+	}
+
+	// A daemon that stops answering fails its health check and is restarted.
+	pid(run("freeze"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if r := run("normal"); r.Error == nil && pid(r) != restarted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the frozen daemon to be restarted")
+		}
+	}
+
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		b.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the daemon to shut down")
+	}
+	data, _ := os.ReadFile(mark)
+	if !strings.Contains(string(data), "cancelled ") || !strings.HasSuffix(string(data), "stopped\n") {
+		t.Errorf("Expected a cancel notification and a graceful shutdown, got %q", data)
+	}
+}
+
+func TestDaemonRefused(t *testing.T) {
+	fakeBun(t)
+	old := daemonMinBackoff
+	daemonMinBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { daemonMinBackoff = old })
+
+	dir := t.TempDir()
+	writeSkill(t, dir, "daemon", `{"name": "daemon", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data", "daemon": true}`, daemonScript)
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	b.Lock = &Lockfile{Skills: map[string]Pin{}}
+	b.RequireApproval = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+
+	reported := make(chan string, 100)
+	go func() {
+		for ev := range h.Outbound {
+			if p, ok := ev.Payload.(bus.ErrorPayload); ok {
+				select {
+				case reported <- p.Message:
+				default:
+				}
+			}
+		}
+	}()
+
+	// An unapproved daemon is reported once and not restarted.
+	time.Sleep(500 * time.Millisecond)
+	var errs []string
+	for len(reported) > 0 {
+		errs = append(errs, <-reported)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], "skill daemon is not approved") {
+		t.Errorf("Expected one error for the refused daemon, got %q", errs)
+	}
+	connCtx, stop := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer stop()
+	if _, err := b.daemons["daemon"].connect(connCtx); err == nil || !strings.Contains(err.Error(), "has shut down") {
+		t.Errorf("Expected the daemon to stay stopped, got %v", err)
+	}
+}
+
+func TestSkillLock(t *testing.T) {
+	fakeBun(t)
+	dir := t.TempDir()
+	writeSkill(t, dir, "balance", balanceManifest, `echo '{"version":1,"type":"info","data":{"eth":"1.0"}}'`)
+	writeSkill(t, dir, "other", `{"name": "other", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"}`, `echo '{"version":1,"type":"info","data":{}}'`)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	lockPath := filepath.Join(t.TempDir(), "skills.lock")
+	lock, err := OpenLockfile(lockPath)
+	if err != nil {
+		t.Fatalf("OpenLockfile failed: %v", err)
+	}
+	b.Lock = lock
+	m, _ := b.Registry().Get("get_balance")
+	if _, err := lock.Approve(m); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+	run := func(skill string) error {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: bus.NewRequestID(), SkillName: skill, Args: map[string]any{"chain": "base"}, ResultChan: results}
+		select {
+		case r := <-results:
+			return r.Error
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return nil
+		}
+	}
+
+	if err := run("../../get_balance"); err == nil || !strings.Contains(err.Error(), "invalid skill name") {
+		t.Errorf("Expected a path in the skill name to be refused, got %v", err)
+	}
+	if err := run("get_balance"); err != nil {
+		t.Errorf("Expected the approved skill to run, got %v", err)
+	}
+	if err := run("other"); err != nil {
+		t.Errorf("Expected unpinned skills to run without RequireApproval, got %v", err)
+	}
+	b.RequireApproval = true
+	if err := run("other"); err == nil || !strings.Contains(err.Error(), "skill other is not approved") {
+		t.Errorf("Expected the unpinned skill to be refused, got %v", err)
+	}
+
+	// Changing any file of the skill voids the approval until it is renewed.
+	script := filepath.Join(dir, "balance", "main.ts")
+	if err := os.WriteFile(script, []byte(`echo '{"version":1,"type":"info","data":{"eth":"999"}}'`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("get_balance"); err == nil || !strings.Contains(err.Error(), "has changed since it was approved") {
+		t.Errorf("Expected the modified skill to be refused, got %v", err)
+	}
+	reopened, err := OpenLockfile(lockPath)
+	if err != nil {
+		t.Fatalf("OpenLockfile failed: %v", err)
+	}
+	if _, err := reopened.Approve(m); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	b.Lock = reopened
+	if err := run("get_balance"); err != nil {
+		t.Errorf("Expected the re-approved skill to run, got %v", err)
+	}
+}
+
+func TestSkillLockSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeSkill(t, dir, "linked", `{"name": "linked", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"}`, `. ./lib.ts`)
+	skill := filepath.Join(dir, "linked")
+	os.MkdirAll(filepath.Join(skill, "src"), 0755)
+	os.WriteFile(filepath.Join(skill, "src", "lib.ts"), []byte("echo 1"), 0644)
+	os.Symlink(filepath.Join("src", "lib.ts"), filepath.Join(skill, "lib.ts"))
+	m, err := LoadManifest(skill)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	b := NewBridge(bus.NewHub(), dir)
+	b.Lock, _ = OpenLockfile(filepath.Join(t.TempDir(), "skills.lock"))
+	if _, err := b.Lock.Approve(m); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if err := b.verify(m); err != nil {
+		t.Fatalf("Expected the approved skill to verify, got %v", err)
+	}
+
+	// Changing the target of a symlink inside the skill voids the approval
+	os.WriteFile(filepath.Join(skill, "src", "lib.ts"), []byte("echo 2"), 0644)
+	if err := b.verify(m); err == nil || !strings.Contains(err.Error(), "has changed since it was approved") {
+		t.Errorf("Expected the changed target to be refused, got %v", err)
+	}
+
+	// Symlinks out of the skill, whose target could change unnoticed, are
+	// refused
+	outside := filepath.Join(t.TempDir(), "lib.ts")
+	os.WriteFile(outside, []byte("echo 1"), 0644)
+	os.Remove(filepath.Join(skill, "lib.ts"))
+	os.Symlink(outside, filepath.Join(skill, "lib.ts"))
+	if _, err := b.Lock.Approve(m); err == nil || !strings.Contains(err.Error(), "symlink lib.ts leaves the skill's directory") {
+		t.Errorf("Expected a symlink out of the skill to be refused, got %v", err)
+	}
+	if err := b.verify(m); err == nil {
+		t.Error("Expected the skill with a symlink out of it to fail verification")
+	}
+}
+
+// fakeSecrets grants the "trade" skill its secrets and refuses any other.
+type fakeSecrets struct{ revealed []string }
+
+func (f *fakeSecrets) Reveal(skill, pin, requestID string, names []string) (map[string]string, error) {
+	f.revealed = append(f.revealed, skill+":"+pin+":"+requestID)
+	if skill != "trade" {
+		return nil, fmt.Errorf("skill %s is not approved", skill)
+	}
+	values := make(map[string]string)
+	for _, name := range names {
+		values[name] = "value-of-" + name
+	}
+	return values, nil
+}
+
+func TestSecretInjection(t *testing.T) {
+	fakeBun(t)
+	dir := t.TempDir()
+	echoRequest := `read -r req; echo "{\"version\":1,\"type\":\"info\",\"data\":$req}"`
+	writeSkill(t, dir, "trade", `{"name": "trade", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data", "secrets": ["CEX_KEY"]}`, echoRequest)
+	writeSkill(t, dir, "rogue", `{"name": "rogue", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data", "secrets": ["CEX_KEY"]}`, echoRequest)
+	writeSkill(t, dir, "plain", `{"name": "plain", "description": "x", "entry": "main.ts", "parameters": {"type": "object"}, "output": "data"}`, echoRequest)
+
+	h := bus.NewHub()
+	b := NewBridge(h, dir)
+	if err := b.LoadSkills(); err != nil {
+		t.Fatalf("LoadSkills failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	go func() {
+		for range h.Outbound {
+		}
+	}()
+	run := func(skill string) bus.ActionResult {
+		results := make(chan bus.ActionResult, 1)
+		h.ActionReq <- bus.Action{RequestID: "req-" + skill, SkillName: skill, Args: map[string]any{}, ResultChan: results}
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the skill")
+			return bus.ActionResult{}
+		}
+	}
+
+	if r := run("trade"); r.Error == nil || !strings.Contains(r.Error.Error(), "no secret store is available") {
+		t.Errorf("Expected secrets to be unavailable without a store, got %v", r.Error)
+	}
+	secrets := &fakeSecrets{}
+	b.Secrets = secrets
+	// The pin of the skill is passed on with the reveal
+	b.Lock, _ = OpenLockfile(filepath.Join(t.TempDir(), "skills.lock"))
+	trade, _ := b.Registry().Get("trade")
+	pin, err := b.Lock.Approve(trade)
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+
+	var req SkillRequest
+	if r := run("trade"); r.Error != nil || json.Unmarshal(r.Output, &req) != nil || req.Secrets["CEX_KEY"] != "value-of-CEX_KEY" {
+		t.Errorf("Expected the secret in the request, got %s, %v", r.Output, r.Error)
+	}
+	if r := run("rogue"); r.Error == nil || !strings.Contains(r.Error.Error(), "secrets for skill rogue unavailable") {
+		t.Errorf("Expected the unapproved skill to be refused, got %v", r.Error)
+	}
+	req = SkillRequest{}
+	if r := run("plain"); r.Error != nil || json.Unmarshal(r.Output, &req) != nil || req.Secrets != nil {
+		t.Errorf("Expected no secrets for a skill that lists none, got %s, %v", r.Output, r.Error)
+	}
+	if want := []string{"trade:" + pin.SHA256 + ":req-trade", "rogue::req-rogue"}; !slices.Equal(secrets.revealed, want) {
+		t.Errorf("Expected reveals %v, got %v", want, secrets.revealed)
+	}
+}
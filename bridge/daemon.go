@@ -0,0 +1,490 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+// Daemon skills ("daemon": true in the manifest) are started with the bridge
+// and kept running between actions, so that they can keep connections and
+// caches warm. They speak JSON-RPC 2.0 with the bridge, one message per line
+// on stdin and stdout:
+//
+//	run       request; params is the SkillRequest, the result its final SkillMessage
+//	progress  notification from the skill; params {"request_id", "message"}
+//	cancel    notification to the skill when a run times out or is cancelled; params {"request_id"}
+//	ping      request sent as a health check; any result will do
+//	shutdown  request sent when the bridge stops; the skill exits after answering
+//
+// A daemon that exits, breaks the protocol or fails a health check is
+// restarted with exponential backoff. Health checks are skipped while a call
+// is in flight, unless the daemon has left a call unanswered since it last
+// spoke.
+
+// Timings of the daemon supervisor; variables so that tests can shorten them.
+var (
+	daemonHealthInterval = 15 * time.Second
+	daemonHealthTimeout  = 5 * time.Second
+	daemonShutdownGrace  = 5 * time.Second
+	daemonMinBackoff     = 500 * time.Millisecond
+	daemonMaxBackoff     = 30 * time.Second
+	// daemonStable is how long a daemon must run for its backoff to reset.
+	daemonStable = time.Minute
+)
+
+var errDaemonExited = errors.New("daemon exited")
+
+// errDaemonRefused marks a daemon that verify refuses, which restarting it
+// cannot fix.
+var errDaemonRefused = errors.New("refused")
+
+// rpcMessage is a JSON-RPC 2.0 request, notification or response.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcConn is the JSON-RPC connection to a running daemon.
+type rpcConn struct {
+	w   io.WriteCloser
+	wmu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan rpcMessage
+	progress map[string]func(string)
+	// stale is set when a call is abandoned without a response, and
+	// cleared by any message from the daemon.
+	stale bool
+
+	// done is closed once the daemon's stdout ends.
+	done chan struct{}
+}
+
+func newRPCConn(w io.WriteCloser) *rpcConn {
+	return &rpcConn{
+		w:        w,
+		pending:  make(map[int64]chan rpcMessage),
+		progress: make(map[string]func(string)),
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *rpcConn) send(msg rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err = c.w.Write(append(data, '\n'))
+	return err
+}
+
+// encodeParams marshals params, leaving them out when nil.
+func encodeParams(params any) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+// notify sends a notification, which gets no response.
+func (c *rpcConn) notify(method string, params any) error {
+	data, err := encodeParams(params)
+	if err != nil {
+		return err
+	}
+	return c.send(rpcMessage{Method: method, Params: data})
+}
+
+// call sends a request and waits for its result.
+func (c *rpcConn) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	data, err := encodeParams(params)
+	if err != nil {
+		return nil, err
+	}
+	resp := make(chan rpcMessage, 1)
+	if c.isClosed() {
+		return nil, errDaemonExited
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = resp
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(rpcMessage{ID: &id, Method: method, Params: data}); err != nil {
+		return nil, err
+	}
+	var msg rpcMessage
+	select {
+	case msg = <-resp:
+	case <-c.done:
+		// The response may have come just before stdout ended.
+		select {
+		case msg = <-resp:
+		default:
+			return nil, errDaemonExited
+		}
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.stale = true
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+// isClosed reports whether the daemon's stdout has ended.
+func (c *rpcConn) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// busy reports whether calls are waiting for their response from a daemon
+// that has answered or spoken since it last left a call unanswered.
+func (c *rpcConn) busy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending) > 0 && !c.stale
+}
+
+// watch routes the progress notifications of a run to progress.
+func (c *rpcConn) watch(requestID string, progress func(string)) func() {
+	c.mu.Lock()
+	c.progress[requestID] = progress
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		delete(c.progress, requestID)
+		c.mu.Unlock()
+	}
+}
+
+// read dispatches the daemon's messages until its stdout ends. Requests from
+// the daemon and unknown notifications are ignored.
+func (c *rpcConn) read(r io.Reader) error {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return fmt.Errorf("invalid JSON-RPC message: %w", err)
+		}
+		if msg.JSONRPC != "2.0" {
+			return fmt.Errorf("unsupported JSON-RPC version %q", msg.JSONRPC)
+		}
+		c.mu.Lock()
+		c.stale = false
+		c.mu.Unlock()
+
+		switch {
+		case msg.Method == "" && msg.ID != nil:
+			c.mu.Lock()
+			resp := c.pending[*msg.ID]
+			c.mu.Unlock()
+			if resp != nil {
+				select {
+				case resp <- msg:
+				default:
+				}
+			}
+		case msg.Method == "progress" && msg.ID == nil:
+			var p struct {
+				RequestID string `json:"request_id"`
+				Message   string `json:"message"`
+			}
+			if json.Unmarshal(msg.Params, &p) != nil || p.Message == "" {
+				continue
+			}
+			c.mu.Lock()
+			progress := c.progress[p.RequestID]
+			c.mu.Unlock()
+			if progress != nil {
+				progress(p.Message)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// daemon supervises the process of a daemon skill.
+type daemon struct {
+	b        *Bridge
+	manifest Manifest
+
+	mu sync.Mutex
+	// conn is nil while the daemon is not running.
+	conn *rpcConn
+	// changed is closed and replaced whenever conn or stopped change.
+	changed chan struct{}
+	stopped bool
+}
+
+// startDaemon supervises the daemon of a skill until ctx ends.
+func (b *Bridge) startDaemon(ctx context.Context, m Manifest) {
+	d := &daemon{b: b, manifest: m, changed: make(chan struct{})}
+	b.mu.Lock()
+	b.daemons[m.Name] = d
+	b.mu.Unlock()
+
+	b.supervisors.Add(1)
+	go func() {
+		defer b.supervisors.Done()
+		d.supervise(ctx)
+	}()
+}
+
+func (d *daemon) set(conn *rpcConn, stopped bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.conn = conn
+	d.stopped = stopped
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// connect waits until the daemon is running.
+func (d *daemon) connect(ctx context.Context) (*rpcConn, error) {
+	for {
+		d.mu.Lock()
+		conn, changed, stopped := d.conn, d.changed, d.stopped
+		d.mu.Unlock()
+		switch {
+		case conn != nil && !conn.isClosed():
+			return conn, nil
+		case stopped:
+			return nil, fmt.Errorf("skill %s has shut down", d.manifest.Name)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// supervise runs the daemon and restarts it with exponential backoff until
+// ctx ends. A daemon that verify refuses is not restarted until the skills
+// are loaded again.
+func (d *daemon) supervise(ctx context.Context) {
+	defer d.set(nil, true)
+	backoff := daemonMinBackoff
+	for {
+		started := time.Now()
+		err := d.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errDaemonRefused) {
+			d.b.hub.Outbound <- bus.ErrorEvent("Skill daemon %s %v", d.manifest.Name, err)
+			return
+		}
+		if time.Since(started) >= daemonStable {
+			backoff = daemonMinBackoff
+		}
+		d.b.hub.Outbound <- bus.ErrorEvent("Skill daemon %s stopped: %v; restarting in %s", d.manifest.Name, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, daemonMaxBackoff)
+	}
+}
+
+// run starts the daemon process and serves it until it exits or fails a
+// health check. When ctx ends, the daemon is shut down gracefully.
+func (d *daemon) run(ctx context.Context) error {
+	m := d.manifest
+	if err := d.b.verify(m); err != nil {
+		return fmt.Errorf("%w: %w", errDaemonRefused, err)
+	}
+	argv, err := d.b.command(m)
+	if err != nil {
+		return err
+	}
+
+	// CPU time adds up over the daemon's life, so it is only limited when
+	// the manifest asks for it.
+	limits := m.Limits.withDefaults()
+	limits.CPU = m.Limits.CPU
+	procCtx, kill := context.WithCancel(context.Background())
+	defer kill()
+	cmd := skillCommand(procCtx, limits, argv[0], argv[1:]...)
+	setProcessGroup(cmd)
+	cleanup, err := d.b.sandbox(cmd, m)
+	if err != nil {
+		return fmt.Errorf("failed to sandbox skill %s: %w", m.Name, err)
+	}
+	defer cleanup()
+	stderr := &tailBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	conn := newRPCConn(stdin)
+	exited := make(chan error, 1)
+	go func() {
+		readErr := conn.read(stdout)
+		if readErr != nil {
+			// The daemon broke the protocol.
+			kill()
+		}
+		waitErr := cmd.Wait()
+		switch {
+		case readErr != nil:
+			exited <- fmt.Errorf("invalid output: %w", readErr)
+		case limitViolation(waitErr, limits) != "":
+			exited <- errors.New(limitViolation(waitErr, limits))
+		case waitErr != nil:
+			exited <- waitErr
+		default:
+			exited <- errDaemonExited
+		}
+	}()
+	d.set(conn, false)
+	defer d.set(nil, false)
+
+	health := time.NewTicker(daemonHealthInterval)
+	defer health.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("%w%s", err, stderrTail(stderr.String()))
+		case <-health.C:
+			// Daemons usually answer one request at a time, so a ping
+			// would wait behind a run in flight. Runs have their own
+			// timeout; once one is left unanswered, pings resume.
+			if conn.busy() {
+				continue
+			}
+			pingCtx, cancel := context.WithTimeout(ctx, daemonHealthTimeout)
+			_, err := conn.call(pingCtx, "ping", nil)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				kill()
+				<-exited
+				return fmt.Errorf("failed health check: %v%s", err, stderrTail(stderr.String()))
+			}
+		case <-ctx.Done():
+			d.set(nil, false)
+			shutdown(conn, kill, exited)
+			return nil
+		}
+	}
+}
+
+// shutdown asks the daemon to exit and kills it if it is still running
+// after daemonShutdownGrace.
+func shutdown(conn *rpcConn, kill func(), exited <-chan error) {
+	ctx, cancel := context.WithTimeout(context.Background(), daemonShutdownGrace)
+	defer cancel()
+	conn.call(ctx, "shutdown", nil)
+	conn.w.Close()
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		kill()
+		<-exited
+	}
+}
+
+// runDaemon sends the request to the skill's daemon and returns its final
+// message. When ctx ends early, the daemon is told to cancel the run.
+func (b *Bridge) runDaemon(ctx context.Context, manifest Manifest, req SkillRequest, progress func(string)) (SkillMessage, error) {
+	name := manifest.Name
+	b.mu.Lock()
+	d := b.daemons[name]
+	b.mu.Unlock()
+	if d == nil {
+		return SkillMessage{}, fmt.Errorf("skill %s is not running", name)
+	}
+	conn, err := d.connect(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The caller reports the timeout or cancellation.
+			return SkillMessage{}, nil
+		}
+		return SkillMessage{}, err
+	}
+	if req.RequestID != "" {
+		defer conn.watch(req.RequestID, progress)()
+	}
+
+	result, err := conn.call(ctx, "run", req)
+	var rpcErr *rpcError
+	switch {
+	case ctx.Err() != nil:
+		conn.notify("cancel", map[string]string{"request_id": req.RequestID})
+		return SkillMessage{}, nil
+	case errors.As(err, &rpcErr):
+		return SkillMessage{Version: ProtocolVersion, Type: MessageError, Message: rpcErr.Message}, nil
+	case err != nil:
+		return SkillMessage{}, fmt.Errorf("failed to execute skill %s: %w", name, err)
+	}
+
+	var msg SkillMessage
+	if err := json.Unmarshal(result, &msg); err != nil {
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %w", name, err)
+	}
+	if err := msg.validate(); err != nil {
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %w", name, err)
+	}
+	if !msg.final() {
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %s is not a result", name, msg.Type)
+	}
+	return msg, nil
+}
@@ -0,0 +1,173 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxInit is the first argument of luccibot when it is re-executed to
+// confine a skill with Landlock: a process can only restrict itself, and Go
+// cannot run code between fork and exec. It is followed by -r and -w paths,
+// then -- and the path and arguments of the command to run.
+const sandboxInit = "__luccibot_sandbox_init"
+
+func init() {
+	if len(os.Args) < 2 || os.Args[1] != sandboxInit {
+		return
+	}
+	// Landlock restricts the calling thread, which must be the one that
+	// execs.
+	runtime.LockOSThread()
+	err := confine(os.Args[2:])
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+// landlockWrap makes cmd run through the sandbox helper, confined to the
+// paths of access.
+func landlockWrap(cmd *exec.Cmd, access fsAccess) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate luccibot for the sandbox: %w", err)
+	}
+	args := []string{self, sandboxInit}
+	for _, p := range access.read {
+		args = append(args, "-r", p)
+	}
+	for _, p := range access.write {
+		args = append(args, "-w", p)
+	}
+	args = append(args, "--", cmd.Path)
+	cmd.Path, cmd.Args = self, append(args, cmd.Args...)
+	return nil
+}
+
+// Landlock access rights by ABI version.
+const (
+	landlockFileRead = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE
+	landlockRead     = landlockFileRead | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockV1       = landlockRead | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	// landlockFile are the rights that apply to files rather than
+	// directories.
+	landlockFile = landlockFileRead | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// confine mounts a private /proc, restricts the process to the paths in args
+// with Landlock and execs the command that follows them. It only returns on
+// failure.
+func confine(args []string) error {
+	var read, write []string
+	for len(args) > 0 && args[0] != "--" {
+		if len(args) < 2 {
+			return errors.New("invalid arguments")
+		}
+		switch args[0] {
+		case "-r":
+			read = append(read, args[1])
+		case "-w":
+			write = append(write, args[1])
+		default:
+			return fmt.Errorf("invalid argument %q", args[0])
+		}
+		args = args[2:]
+	}
+	if len(args) < 3 {
+		return errors.New("missing command")
+	}
+	path, argv := args[1], args[2:]
+
+	if err := privateProc(); err != nil {
+		return err
+	}
+
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("Landlock is not available: %w", errno)
+	}
+	handled := uint64(landlockV1)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	// Only the first field of the attributes exists in every ABI version.
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr.Access_fs), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create Landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	for _, rule := range []struct {
+		paths  []string
+		access uint64
+	}{{read, landlockRead}, {write, handled}} {
+		for _, p := range rule.paths {
+			if err := allowPath(int(fd), p, rule.access&handled); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce Landlock ruleset: %w", errno)
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// privateProc replaces /proc with one of the sandbox's PID namespace, so
+// that the skill cannot read the environment or memory of luccibot, then
+// drops the capabilities that allowed it.
+func privateProc() error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to drop capabilities: %w", err)
+	}
+	return nil
+}
+
+// allowPath grants access beneath path; paths that do not exist are
+// skipped.
+func allowPath(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFile
+	}
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to allow %s: %w", path, errno)
+	}
+	return nil
+}
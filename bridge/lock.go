@@ -0,0 +1,183 @@
+package bridge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Lockfile pins every approved skill to the SHA-256 hash of its directory,
+// so that a skill whose files change is refused until it is approved again.
+type Lockfile struct {
+	path string
+	mu   sync.Mutex
+	// Skills maps a skill name to its pin.
+	Skills map[string]Pin `json:"skills"`
+}
+
+// Pin is the approved state of a skill.
+type Pin struct {
+	SHA256     string    `json:"sha256"`
+	ApprovedAt time.Time `json:"approved_at"`
+}
+
+// DefaultLockPath returns the default lockfile path.
+// Usually ~/.luccibot/skills.lock
+func DefaultLockPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".luccibot", "skills.lock"), nil
+}
+
+// OpenLockfile loads the lockfile at path, starting empty if it does not exist.
+func OpenLockfile(path string) (*Lockfile, error) {
+	l := &Lockfile{path: path, Skills: make(map[string]Pin)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("failed to read skill lockfile: %w", err)
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal skill lockfile: %w", err)
+	}
+	if l.Skills == nil {
+		l.Skills = make(map[string]Pin)
+	}
+	return l, nil
+}
+
+// Pinned returns the pin of the named skill.
+func (l *Lockfile) Pinned(name string) (Pin, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pin, ok := l.Skills[name]
+	return pin, ok
+}
+
+// Approve pins the skill to the current hash of its files and saves the
+// lockfile.
+func (l *Lockfile) Approve(m Manifest) (Pin, error) {
+	sum, err := HashSkill(m)
+	if err != nil {
+		return Pin{}, err
+	}
+	pin := Pin{SHA256: sum, ApprovedAt: time.Now().UTC()}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Skills[m.Name] = pin
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return Pin{}, fmt.Errorf("failed to create lockfile directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return Pin{}, fmt.Errorf("failed to marshal skill lockfile: %w", err)
+	}
+	if err := os.WriteFile(l.path, data, 0600); err != nil {
+		return Pin{}, fmt.Errorf("failed to write skill lockfile: %w", err)
+	}
+	return pin, nil
+}
+
+// HashSkill returns the hex SHA-256 hash of the files in the skill's
+// directory, manifest included. Every file contributes its path relative to
+// the directory and the hash of its contents, in lexical order; symlinks
+// contribute their target, which is hashed as a file of the skill itself.
+// Symlinks that resolve outside the directory, whose target could change
+// without changing the hash, are refused, as are broken ones.
+func HashSkill(m Manifest) (string, error) {
+	root, err := filepath.EvalSymlinks(m.Dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash skill %s: %w", m.Name, err)
+	}
+	h := sha256.New()
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var sum string
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return fmt.Errorf("symlink %s is broken: %w", rel, err)
+			}
+			if r, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(r) {
+				return fmt.Errorf("symlink %s leaves the skill's directory", rel)
+			}
+			sum = "symlink:" + target
+		case d.Type().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			fh := sha256.New()
+			_, err = io.Copy(fh, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			sum = hex.EncodeToString(fh.Sum(nil))
+		default:
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash skill %s: %w", m.Name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verify refuses skills whose entry leaves their directory, whose files no
+// longer match their pin and, when the bridge requires approval, skills that
+// are not pinned. Skills without a directory, such as Go skills, are not
+// checked.
+func (b *Bridge) verify(m Manifest) error {
+	if m.Dir == "" {
+		return nil
+	}
+	if err := m.checkEntry(); err != nil {
+		return err
+	}
+	if b.Lock == nil {
+		return nil
+	}
+	pin, ok := b.Lock.Pinned(m.Name)
+	if !ok {
+		if b.RequireApproval {
+			return fmt.Errorf("skill %s is not approved; run `luccibot skills approve %s` to allow it", m.Name, m.Name)
+		}
+		return nil
+	}
+	sum, err := HashSkill(m)
+	if err != nil {
+		return err
+	}
+	if sum != pin.SHA256 {
+		return fmt.Errorf("skill %s has changed since it was approved; run `luccibot skills approve %s` to allow it", m.Name, m.Name)
+	}
+	return nil
+}
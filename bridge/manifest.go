@@ -0,0 +1,223 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// ManifestFile is the name of the manifest in a skill's directory.
+const ManifestFile = "skill.json"
+
+// OutputKind tells which results a skill may return.
+type OutputKind string
+
+const (
+	// OutputData skills only return info results to the agent.
+	OutputData OutputKind = "data"
+	// OutputTransaction skills may also ask for transactions to be signed.
+	OutputTransaction OutputKind = "transaction"
+)
+
+// Permissions a skill can ask for. Config lets WASM skills read their
+// settings.
+const (
+	PermissionNetwork    = "network"
+	PermissionFilesystem = "filesystem"
+	PermissionSign       = "sign"
+	PermissionConfig     = "config"
+)
+
+var knownPermissions = map[string]bool{
+	PermissionNetwork:    true,
+	PermissionFilesystem: true,
+	PermissionSign:       true,
+	PermissionConfig:     true,
+}
+
+// skillName is also a valid LLM function name.
+var skillName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Manifest describes a skill: how to run it and what it accepts and returns.
+type Manifest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Entry is the script to run, relative to the skill's directory.
+	Entry string `json:"entry,omitempty"`
+	// Runtime runs the entry, see runtime.go; empty infers it from the
+	// entry's extension.
+	Runtime string `json:"runtime,omitempty"`
+	// Parameters is the JSON Schema of the skill's arguments.
+	Parameters map[string]any `json:"parameters"`
+	Output     OutputKind     `json:"output"`
+	// Permissions lists what the skill needs: network, filesystem, sign,
+	// config.
+	Permissions []string `json:"permissions,omitempty"`
+	// Timeout is the maximum run time in seconds; zero uses the bridge default.
+	Timeout int `json:"timeout,omitempty"`
+	// Concurrency is the number of runs of the skill allowed at once; zero
+	// only applies the bridge's limits.
+	Concurrency int `json:"concurrency,omitempty"`
+	// Env lists the environment variables passed on to the skill.
+	Env []string `json:"env,omitempty"`
+	// Limits overrides the default resource limits of the skill.
+	Limits Limits `json:"limits,omitempty"`
+	// Hosts lists the hosts a WASM skill may send HTTP requests to; a
+	// leading "*." also matches subdomains.
+	Hosts []string `json:"hosts,omitempty"`
+	// Secrets lists the secrets from the vault the skill receives in its
+	// request, once the user has approved them.
+	Secrets []string `json:"secrets,omitempty"`
+	// Daemon keeps the skill running between actions as a supervised
+	// process that speaks JSON-RPC 2.0, see daemon.go.
+	Daemon bool `json:"daemon,omitempty"`
+	// Specialist names the agent specialist that calls the skill, such as
+	// trading; empty or unknown names leave it to the skills specialist.
+	Specialist string `json:"specialist,omitempty"`
+
+	// Dir is the skill's directory, set when the manifest is loaded.
+	Dir string `json:"-"`
+}
+
+// LoadManifest reads and validates the manifest in a skill directory.
+func LoadManifest(dir string) (Manifest, error) {
+	path := filepath.Join(dir, ManifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", path, err)
+	}
+	m.Dir = dir
+	if err := m.Validate(); err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.checkEntry(); err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// checkEntry refuses entries that resolve, through symlinks, to a file
+// outside the skill's directory. A missing entry is left to the runtime.
+func (m Manifest) checkEntry() error {
+	if m.Dir == "" || m.Entry == "" {
+		return nil
+	}
+	dir, err := filepath.EvalSymlinks(m.Dir)
+	if err != nil {
+		return err
+	}
+	entry, err := filepath.EvalSymlinks(filepath.Join(m.Dir, m.Entry))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(dir, entry); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("entry of skill %s leaves its directory", m.Name)
+	}
+	return nil
+}
+
+// Validate checks that the manifest is complete and consistent.
+func (m Manifest) Validate() error {
+	if !skillName.MatchString(m.Name) {
+		return fmt.Errorf("invalid skill name %q: use lowercase letters, digits and underscores", m.Name)
+	}
+	if m.Description == "" {
+		return fmt.Errorf("skill %s has no description", m.Name)
+	}
+	if m.Entry == "" && m.Runtime != RuntimeGo {
+		return fmt.Errorf("skill %s has no entry", m.Name)
+	}
+	if m.Entry != "" && !filepath.IsLocal(m.Entry) {
+		return fmt.Errorf("entry of skill %s leaves its directory", m.Name)
+	}
+	if m.Parameters == nil {
+		return fmt.Errorf("skill %s has no parameters schema", m.Name)
+	}
+	if t, _ := m.Parameters["type"].(string); t != "object" {
+		return fmt.Errorf("parameters schema of skill %s must be of type object", m.Name)
+	}
+
+	if m.Specialist != "" && !skillName.MatchString(m.Specialist) {
+		return fmt.Errorf("skill %s names invalid specialist %q", m.Name, m.Specialist)
+	}
+
+	if m.Timeout < 0 {
+		return fmt.Errorf("skill %s has a negative timeout", m.Name)
+	}
+	if m.Concurrency < 0 {
+		return fmt.Errorf("skill %s has a negative concurrency", m.Name)
+	}
+	if m.Limits.CPU < 0 || m.Limits.Memory < 0 || m.Limits.Files < 0 {
+		return fmt.Errorf("skill %s has negative limits", m.Name)
+	}
+	if len(m.Hosts) > 0 && !m.HasPermission(PermissionNetwork) {
+		return fmt.Errorf("skill %s lists hosts but lacks the %q permission", m.Name, PermissionNetwork)
+	}
+	if rt := m.RuntimeName(); m.Daemon && (rt == RuntimeGo || rt == RuntimeWasm) {
+		return fmt.Errorf("skill %s cannot run as a daemon with the %s runtime", m.Name, rt)
+	}
+	for _, name := range m.Env {
+		if !envName.MatchString(name) {
+			return fmt.Errorf("skill %s asks for invalid environment variable %q", m.Name, name)
+		}
+		if reservedEnv[name] {
+			return fmt.Errorf("skill %s may not receive %s", m.Name, name)
+		}
+	}
+	for i, name := range m.Secrets {
+		if !envName.MatchString(name) {
+			return fmt.Errorf("skill %s asks for invalid secret %q", m.Name, name)
+		}
+		if slices.Contains(m.Secrets[:i], name) {
+			return fmt.Errorf("skill %s asks for secret %s twice", m.Name, name)
+		}
+	}
+
+	switch m.Output {
+	case OutputData:
+	case OutputTransaction:
+		if !m.HasPermission(PermissionSign) {
+			return fmt.Errorf("skill %s outputs transactions but lacks the %q permission", m.Name, PermissionSign)
+		}
+	default:
+		return fmt.Errorf("skill %s has unknown output kind %q", m.Name, m.Output)
+	}
+
+	for _, p := range m.Permissions {
+		if !knownPermissions[p] {
+			return fmt.Errorf("skill %s asks for unknown permission %q", m.Name, p)
+		}
+	}
+	return nil
+}
+
+// HasPermission reports whether the manifest asks for the permission.
+func (m Manifest) HasPermission(p string) bool {
+	for _, have := range m.Permissions {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
+
+// path returns the absolute path of the entry.
+func (m Manifest) path() string {
+	path := filepath.Join(m.Dir, m.Entry)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
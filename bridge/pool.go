@@ -0,0 +1,155 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+// DefaultMaxConcurrent is the number of skills run at once when the bridge
+// sets no limit.
+const DefaultMaxConcurrent = 4
+
+// maxSigning is the number of transaction skills run at once, so that
+// signature requests reach the vault one at a time.
+const maxSigning = 1
+
+// job is an action waiting in the worker pool.
+type job struct {
+	ctx      context.Context
+	action   bus.Action
+	manifest Manifest
+}
+
+// submit validates the action and queues it for the worker pool. Invalid
+// actions are answered right away.
+func (b *Bridge) submit(ctx context.Context, action bus.Action) {
+	// The log line shares the action's IDs so the TUI can link later events to it.
+	b.announce(action.RequestID, action.ParentID, "Skill %s requested (%s)", action.SkillName, action.RequestID)
+
+	manifest, err := b.prepare(action)
+	if err != nil {
+		b.fail(action, err)
+		return
+	}
+
+	b.mu.Lock()
+	b.queue = append(b.queue, job{ctx: ctx, action: action, manifest: manifest})
+	queued, running := len(b.queue), b.active
+	b.mu.Unlock()
+	b.status(action, bus.SkillQueued, queued, running)
+
+	b.schedule()
+}
+
+// schedule starts every queued job that fits within the limits, in queue
+// order. A job held back by the limit of its skill does not hold up the
+// jobs behind it.
+func (b *Bridge) schedule() {
+	b.mu.Lock()
+	var start []job
+	rest := b.queue[:0]
+	for _, j := range b.queue {
+		if b.fits(j.manifest) {
+			b.acquire(j.manifest, 1)
+			start = append(start, j)
+		} else {
+			rest = append(rest, j)
+		}
+	}
+	clear(b.queue[len(rest):])
+	b.queue = rest
+	queued, running := len(b.queue), b.active
+	b.mu.Unlock()
+
+	for _, j := range start {
+		b.status(j.action, bus.SkillRunning, queued, running)
+		go b.run(j)
+	}
+}
+
+// run executes a job, frees its slot and starts the jobs waiting for it.
+func (b *Bridge) run(j job) {
+	b.executeSkill(j.ctx, j.action, j.manifest)
+
+	b.mu.Lock()
+	b.acquire(j.manifest, -1)
+	queued, running := len(b.queue), b.active
+	b.mu.Unlock()
+	b.status(j.action, bus.SkillFinished, queued, running)
+
+	b.schedule()
+}
+
+// fits reports whether a skill can start now. b.mu must be held.
+func (b *Bridge) fits(m Manifest) bool {
+	limit := b.MaxConcurrent
+	if limit <= 0 {
+		limit = DefaultMaxConcurrent
+	}
+	if b.active >= limit {
+		return false
+	}
+	if m.Concurrency > 0 && b.bySkill[m.Name] >= m.Concurrency {
+		return false
+	}
+	return m.Output != OutputTransaction || b.signing < maxSigning
+}
+
+// acquire takes (n = 1) or frees (n = -1) the slots of a skill. b.mu must
+// be held.
+func (b *Bridge) acquire(m Manifest, n int) {
+	b.active += n
+	b.bySkill[m.Name] += n
+	if b.bySkill[m.Name] == 0 {
+		delete(b.bySkill, m.Name)
+	}
+	if m.Output == OutputTransaction {
+		b.signing += n
+	}
+}
+
+// Cancel kills the skill running for the Action with the given RequestID,
+// or every running and queued skill if id is empty. Queued actions are
+// answered with an error. It reports whether any skill was cancelled.
+func (b *Bridge) Cancel(id string) bool {
+	b.mu.Lock()
+	found := false
+	for runID, cancel := range b.running {
+		if id == "" || id == runID {
+			cancel(errSkillCancelled)
+			found = true
+		}
+	}
+	var dropped []job
+	rest := b.queue[:0]
+	for _, j := range b.queue {
+		if id == "" || id == j.action.RequestID {
+			dropped = append(dropped, j)
+		} else {
+			rest = append(rest, j)
+		}
+	}
+	clear(b.queue[len(rest):])
+	b.queue = rest
+	queued, running := len(b.queue), b.active
+	b.mu.Unlock()
+
+	for _, j := range dropped {
+		b.fail(j.action, fmt.Errorf("skill %s %v", j.action.SkillName, errSkillCancelled))
+		b.status(j.action, bus.SkillFinished, queued, running)
+	}
+	return found || len(dropped) > 0
+}
+
+// status publishes a change in the state of an action.
+func (b *Bridge) status(action bus.Action, s bus.SkillStatus, queued, running int) {
+	b.hub.Outbound <- bus.NewEvent(bus.SkillStatusPayload{
+		ActionID: action.RequestID,
+		Skill:    action.SkillName,
+		Status:   s,
+		Queued:   queued,
+		Running:  running,
+	}).WithParent(action.RequestID)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package bridge
+
+import (
+	"context"
+	"os/exec"
+)
+
+// skillCommand runs name with args; resource limits are not supported here.
+func skillCommand(ctx context.Context, l Limits, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// setProcessGroup is a no-op where process groups are not supported;
+// cancellation only kills the skill process itself.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// limitViolation always reports nothing where limits are not supported.
+func limitViolation(err error, l Limits) string {
+	return ""
+}
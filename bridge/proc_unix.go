@@ -0,0 +1,56 @@
+//go:build unix
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// skillCommand runs name with args under the resource limits, set by a
+// shell wrapper before it execs the skill runtime. The hard CPU limit is a
+// second above the soft one, so the skill gets SIGXCPU before SIGKILL; a
+// zero CPU limit leaves CPU time unlimited.
+func skillCommand(ctx context.Context, l Limits, name string, args ...string) *exec.Cmd {
+	script := fmt.Sprintf(`ulimit -d %d && ulimit -n %d && exec "$0" "$@"`, l.Memory*1024, l.Files)
+	if l.CPU > 0 {
+		script = fmt.Sprintf(`ulimit -S -t %d && ulimit -H -t %d && `, l.CPU, l.CPU+1) + script
+	}
+	return exec.CommandContext(ctx, "sh", append([]string{"-c", script, name}, args...)...)
+}
+
+// setProcessGroup starts the skill in its own process group and makes
+// cancellation kill the whole group, so that children spawned by the skill
+// runtime do not outlive it.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// limitViolation describes the resource limit the skill was killed for, if
+// any.
+func limitViolation(err error, l Limits) string {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ""
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	switch status.Signal() {
+	case syscall.SIGXCPU:
+		return fmt.Sprintf("exceeded its CPU limit of %ds", l.CPU)
+	case syscall.SIGXFSZ:
+		return "exceeded its file size limit"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProtocolVersion is the version of the skill envelope protocol spoken by
+// this bridge. See docs/skill-protocol.md.
+const ProtocolVersion = 1
+
+// SkillRequest is written as JSON to a skill's stdin.
+type SkillRequest struct {
+	Version   int            `json:"version"`
+	RequestID string         `json:"request_id"`
+	Action    string         `json:"action"`
+	Params    map[string]any `json:"params"`
+	Chain     string         `json:"chain,omitempty"`
+	Account   string         `json:"account,omitempty"`
+	// Secrets holds the secrets listed in the manifest, by name.
+	Secrets map[string]string `json:"secrets,omitempty"`
+}
+
+// MessageType is the type of a message written by a skill.
+type MessageType string
+
+const (
+	// MessageTxRequest asks for a transaction to be signed; it ends the run.
+	MessageTxRequest MessageType = "tx_request"
+	// MessageInfo returns data to the agent; it ends the run.
+	MessageInfo MessageType = "info"
+	// MessageError reports a failure; it ends the run.
+	MessageError MessageType = "error"
+	// MessageProgress reports a step; the skill keeps running.
+	MessageProgress MessageType = "progress"
+)
+
+// SkillMessage is one JSON line written by a skill to its stdout.
+type SkillMessage struct {
+	Version int         `json:"version"`
+	Type    MessageType `json:"type"`
+	// Tx is the transaction to sign, for tx_request.
+	Tx json.RawMessage `json:"tx,omitempty"`
+	// Chain the transaction is for, for tx_request.
+	Chain string `json:"chain,omitempty"`
+	// Data is the result of an info message.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Message describes an error or progress step.
+	Message string `json:"message,omitempty"`
+}
+
+// validate checks a message against the protocol.
+func (m SkillMessage) validate() error {
+	if m.Version != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d (want %d)", m.Version, ProtocolVersion)
+	}
+	switch m.Type {
+	case MessageTxRequest:
+		if len(m.Tx) == 0 || string(m.Tx) == "null" {
+			return fmt.Errorf("tx_request without tx")
+		}
+	case MessageInfo:
+		if len(m.Data) == 0 {
+			return fmt.Errorf("info without data")
+		}
+	case MessageError, MessageProgress:
+		if m.Message == "" {
+			return fmt.Errorf("%s without message", m.Type)
+		}
+	default:
+		return fmt.Errorf("unknown message type %q", m.Type)
+	}
+	return nil
+}
+
+// final reports whether the message ends the skill's run.
+func (m SkillMessage) final() bool {
+	return m.Type != MessageProgress
+}
+
+// encodeRequest renders the request written to the skill's stdin.
+func encodeRequest(req SkillRequest) ([]byte, error) {
+	req.Version = ProtocolVersion
+	if req.Params == nil {
+		req.Params = map[string]any{}
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// readMessages reads the skill's stdout, handing progress messages to
+// onProgress, and returns the final message. Anything after the final
+// message, or stdout ending without one, is a protocol error.
+func readMessages(r io.Reader, onProgress func(string)) (SkillMessage, error) {
+	var result *SkillMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if result != nil {
+			return SkillMessage{}, fmt.Errorf("output after the final %s message", result.Type)
+		}
+
+		var msg SkillMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return SkillMessage{}, fmt.Errorf("invalid message %q: %w", truncate(string(line), 80), err)
+		}
+		if err := msg.validate(); err != nil {
+			return SkillMessage{}, err
+		}
+		if !msg.final() {
+			if onProgress != nil {
+				onProgress(msg.Message)
+			}
+			continue
+		}
+		result = &msg
+	}
+	if err := scanner.Err(); err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to read skill output: %w", err)
+	}
+	if result == nil {
+		return SkillMessage{}, fmt.Errorf("skill exited without a result")
+	}
+	return *result, nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
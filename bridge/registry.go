@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Registry holds the manifests of the installed skills.
+type Registry struct {
+	mu     sync.RWMutex
+	skills map[string]Manifest
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		skills: make(map[string]Manifest),
+	}
+}
+
+// Register validates and adds a skill.
+func (r *Registry) Register(m Manifest) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.skills[m.Name]; exists {
+		return fmt.Errorf("skill %q already registered", m.Name)
+	}
+	r.skills[m.Name] = m
+	return nil
+}
+
+// Scan registers the skill of every subdirectory of dir that holds a
+// manifest. Invalid manifests are skipped and reported together in the
+// returned error; the valid ones are registered regardless. A missing dir
+// holds no skills.
+func (r *Registry) Scan(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read skills directory: %w", err)
+	}
+
+	var errs []error
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		skillDir := filepath.Join(dir, e.Name())
+		m, err := LoadManifest(skillDir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = r.Register(m)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns the manifest of the named skill.
+func (r *Registry) Get(name string) (Manifest, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.skills[name]
+	return m, ok
+}
+
+// List returns the manifests sorted by name.
+func (r *Registry) List() []Manifest {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Manifest, 0, len(r.skills))
+	for _, m := range r.skills {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Len returns the number of registered skills.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.skills)
+}
@@ -0,0 +1,157 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Runtimes a manifest can name. Without a runtime the entry's extension
+// decides: bun for JavaScript and TypeScript, python for .py, wasm for
+// .wasm, exec otherwise.
+const (
+	RuntimeBun    = "bun"
+	RuntimeNode   = "node"
+	RuntimeDeno   = "deno"
+	RuntimePython = "python"
+	// RuntimeExec runs the entry itself, which must be executable.
+	RuntimeExec = "exec"
+	// RuntimeWasm runs a WebAssembly module in the bridge's process, see
+	// wasm.go.
+	RuntimeWasm = "wasm"
+	// RuntimeGo runs a GoSkill registered with Bridge.RegisterGo in the
+	// bridge's own process.
+	RuntimeGo = "go"
+)
+
+// Runtime builds the command line that runs the entry script of a skill.
+// entry is an absolute path.
+type Runtime interface {
+	Command(m Manifest, entry string) []string
+}
+
+// RuntimeFunc adapts a function to the Runtime interface.
+type RuntimeFunc func(m Manifest, entry string) []string
+
+func (f RuntimeFunc) Command(m Manifest, entry string) []string {
+	return f(m, entry)
+}
+
+func defaultRuntimes() map[string]Runtime {
+	return map[string]Runtime{
+		RuntimeBun: RuntimeFunc(func(m Manifest, entry string) []string {
+			return []string{"bun", entry}
+		}),
+		RuntimeNode: RuntimeFunc(func(m Manifest, entry string) []string {
+			return []string{"node", entry}
+		}),
+		RuntimeDeno: RuntimeFunc(denoCommand),
+		RuntimePython: RuntimeFunc(func(m Manifest, entry string) []string {
+			return []string{"python3", entry}
+		}),
+		RuntimeExec: RuntimeFunc(func(m Manifest, entry string) []string {
+			return []string{entry}
+		}),
+	}
+}
+
+// denoCommand grants Deno the permissions of the manifest and nothing more.
+func denoCommand(m Manifest, entry string) []string {
+	args := []string{"deno", "run", "--quiet", "--no-prompt"}
+	if m.HasPermission(PermissionNetwork) {
+		args = append(args, "--allow-net")
+	}
+	if m.HasPermission(PermissionFilesystem) {
+		args = append(args, "--allow-read=.", "--allow-write=.")
+	}
+	if len(m.Env) > 0 {
+		args = append(args, "--allow-env="+strings.Join(m.Env, ","))
+	}
+	return append(args, entry)
+}
+
+// RuntimeName returns the runtime of the skill: the manifest's, or the one
+// inferred from the entry's extension.
+func (m Manifest) RuntimeName() string {
+	if m.Runtime != "" {
+		return m.Runtime
+	}
+	switch strings.ToLower(filepath.Ext(m.Entry)) {
+	case ".ts", ".tsx", ".js", ".mjs", ".cjs":
+		return RuntimeBun
+	case ".py":
+		return RuntimePython
+	case ".wasm":
+		return RuntimeWasm
+	}
+	return RuntimeExec
+}
+
+// SetRuntime adds or replaces the runtime manifests refer to by name. Call
+// it before Start.
+func (b *Bridge) SetRuntime(name string, r Runtime) {
+	b.runtimes[name] = r
+}
+
+// command returns the command line of a skill process.
+func (b *Bridge) command(m Manifest) ([]string, error) {
+	name := m.RuntimeName()
+	r, ok := b.runtimes[name]
+	if !ok {
+		return nil, fmt.Errorf("skill %s needs unknown runtime %q", m.Name, name)
+	}
+	argv := r.Command(m, m.path())
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("runtime %q of skill %s returned no command", name, m.Name)
+	}
+	return argv, nil
+}
+
+// GoSkill is a skill written in Go and run in the bridge's process, without
+// the sandbox. It speaks the skill protocol: steps go to progress and the
+// result is an info, tx_request or error message. Run must return once ctx
+// is done.
+type GoSkill interface {
+	Run(ctx context.Context, req SkillRequest, progress func(step string)) (SkillMessage, error)
+}
+
+// GoSkillFunc adapts a function to the GoSkill interface.
+type GoSkillFunc func(ctx context.Context, req SkillRequest, progress func(step string)) (SkillMessage, error)
+
+func (f GoSkillFunc) Run(ctx context.Context, req SkillRequest, progress func(step string)) (SkillMessage, error) {
+	return f(ctx, req, progress)
+}
+
+// RegisterGo registers a skill implemented in Go under the manifest, whose
+// runtime is set to RuntimeGo. Call it before Start.
+func (b *Bridge) RegisterGo(m Manifest, skill GoSkill) error {
+	m.Runtime = RuntimeGo
+	if err := b.registry.Register(m); err != nil {
+		return err
+	}
+	b.goSkills[m.Name] = skill
+	return nil
+}
+
+// runGo runs a Go skill and checks its result against the protocol.
+func (b *Bridge) runGo(ctx context.Context, m Manifest, req SkillRequest, progress func(string)) (SkillMessage, error) {
+	skill, ok := b.goSkills[m.Name]
+	if !ok {
+		return SkillMessage{}, fmt.Errorf("skill %s has no registered Go implementation", m.Name)
+	}
+	msg, err := skill.Run(ctx, req, progress)
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("skill %s failed: %w", m.Name, err)
+	}
+	if msg.Version == 0 {
+		msg.Version = ProtocolVersion
+	}
+	if err := msg.validate(); err != nil {
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %w", m.Name, err)
+	}
+	if !msg.final() {
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %s is not a result", m.Name, msg.Type)
+	}
+	return msg, nil
+}
@@ -0,0 +1,149 @@
+package bridge
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Resource limits applied to skills whose manifest sets none.
+const (
+	DefaultCPULimit    = 60   // seconds of CPU time
+	DefaultMemoryLimit = 1024 // MB of data segment
+	DefaultFilesLimit  = 256  // open file descriptors
+)
+
+// Limits are the resource limits of a skill process. Zero fields select
+// the defaults.
+type Limits struct {
+	// CPU is the CPU time in seconds.
+	CPU int `json:"cpu,omitempty"`
+	// Memory is the size of the data segment in MB.
+	Memory int `json:"memory,omitempty"`
+	// Files is the number of open file descriptors.
+	Files int `json:"files,omitempty"`
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.CPU <= 0 {
+		l.CPU = DefaultCPULimit
+	}
+	if l.Memory <= 0 {
+		l.Memory = DefaultMemoryLimit
+	}
+	if l.Files <= 0 {
+		l.Files = DefaultFilesLimit
+	}
+	return l
+}
+
+// baseEnv lists the variables every skill inherits.
+var baseEnv = []string{"PATH", "LANG", "LC_ALL", "TZ"}
+
+// reservedEnv lists the credentials of luccibot itself, which no manifest
+// may ask for.
+var reservedEnv = map[string]bool{
+	"GEMINI_API_KEY":  true,
+	"GOOGLE_API_KEY":  true,
+	"OPENAI_API_KEY":  true,
+	"OPENAI_BASE_URL": true,
+	// Skills get their secrets from the vault instead.
+	"LUCCIBOT_VAULT_PASSPHRASE": true,
+}
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// systemPaths are the directories an isolated skill may read and execute
+// besides its own: the system's programs, libraries and configuration, and
+// its private /proc. /sys is left out.
+var systemPaths = []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/etc", "/opt", "/nix", "/proc"}
+
+// fsAccess lists the paths an isolated skill may use; the rest of the
+// filesystem is out of its reach.
+type fsAccess struct {
+	// read may be read and executed.
+	read []string
+	// write may also be modified.
+	write []string
+}
+
+// confinement returns what an isolated skill may access: the system paths, its
+// own directory and runtime read-only, and its temp directory and the
+// devices read-write.
+func (b *Bridge) confinement(m Manifest, tmp string) (fsAccess, error) {
+	dir, err := filepath.Abs(m.Dir)
+	if err != nil {
+		return fsAccess{}, err
+	}
+	access := fsAccess{read: append(slices.Clone(systemPaths), dir), write: []string{tmp, "/dev"}}
+	argv, err := b.command(m)
+	if err != nil {
+		return fsAccess{}, err
+	}
+	if path, err := exec.LookPath(argv[0]); err == nil {
+		access.read = append(access.read, runtimeRoot(path))
+	}
+	return access, nil
+}
+
+// runtimeRoot returns the directory a runtime is installed in: the prefix
+// of <prefix>/bin/<runtime>, which usually holds its libraries too, or the
+// directory of the executable. A prefix holding the home directory is too
+// broad, so the executable's directory is used instead.
+func runtimeRoot(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) != "bin" {
+		return dir
+	}
+	prefix := filepath.Dir(dir)
+	home, err := os.UserHomeDir()
+	if err != nil || prefix == "/" || strings.HasPrefix(home+"/", strings.TrimSuffix(prefix, "/")+"/") {
+		return dir
+	}
+	return prefix
+}
+
+// sandbox confines cmd: it runs in a private temp directory, which is also
+// its HOME and TMPDIR, with only the base and allowlisted variables of the
+// environment, and in its own namespaces when the bridge isolates skills.
+// Isolated skills can only use the paths of confinement. The returned function
+// removes the temp directory.
+func (b *Bridge) sandbox(cmd *exec.Cmd, m Manifest) (func(), error) {
+	dir, err := os.MkdirTemp("", "luccibot-"+m.Name+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	cmd.Dir = dir
+	cmd.Env = []string{"HOME=" + dir, "TMPDIR=" + dir}
+	for _, names := range [][]string{baseEnv, m.Env} {
+		for _, name := range names {
+			if v, ok := os.LookupEnv(name); ok {
+				cmd.Env = append(cmd.Env, name+"="+v)
+			}
+		}
+	}
+
+	if b.Isolate {
+		access, err := b.confinement(m, dir)
+		if err == nil {
+			err = isolate(cmd, m.HasPermission(PermissionNetwork), access)
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+	return cleanup, nil
+}
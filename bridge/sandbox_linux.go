@@ -0,0 +1,36 @@
+package bridge
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// isolate runs cmd in new user, PID, mount, IPC and UTS namespaces, and in a
+// new network namespace without interfaces unless the skill may use the
+// network. The user keeps its IDs inside the user namespace. The sandbox
+// helper mounts a private /proc, which only shows the skill's own processes,
+// with the CAP_SYS_ADMIN it gets in the namespace and drops. The filesystem is
+// confined with Landlock to the paths of access, so that the keystore,
+// wallet, secrets and journal in ~/.luccibot are out of reach; kernels
+// without Landlock (before 5.13) fail the skill rather than run it
+// unconfined.
+func isolate(cmd *exec.Cmd, network bool, access fsAccess) error {
+	if err := landlockWrap(cmd, access); err != nil {
+		return err
+	}
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags = uintptr(flags)
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	cmd.SysProcAttr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN}
+	return nil
+}
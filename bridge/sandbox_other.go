@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bridge
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// isolate is only supported on Linux.
+func isolate(cmd *exec.Cmd, network bool, access fsAccess) error {
+	return errors.New("skill isolation requires Linux namespaces")
+}
@@ -0,0 +1,199 @@
+package bridge
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateArgs checks args against a JSON Schema. It supports the subset of
+// JSON Schema used by skill manifests: type, properties, required,
+// additionalProperties, items, enum, minimum, maximum, minLength, maxLength
+// and pattern.
+func ValidateArgs(schema map[string]any, args map[string]any) error {
+	if args == nil {
+		args = map[string]any{}
+	}
+	return validate(schema, args, "")
+}
+
+func validate(schema map[string]any, v any, path string) error {
+	if t, ok := schema["type"].(string); ok && !hasType(v, t) {
+		return fmt.Errorf("%s: expected %s, got %s", where(path), t, typeName(v))
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(normalize(e), normalize(v)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: must be one of %v", where(path), enum)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		return validateObject(schema, v, path)
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if min, ok := number(schema["minLength"]); ok && float64(n) < min {
+			return fmt.Errorf("%s: must be at least %v characters", where(path), min)
+		}
+		if max, ok := number(schema["maxLength"]); ok && float64(n) > max {
+			return fmt.Errorf("%s: must be at most %v characters", where(path), max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern in schema: %w", where(path), err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: does not match %s", where(path), pattern)
+			}
+		}
+	default:
+		if f, ok := number(v); ok {
+			if min, ok := number(schema["minimum"]); ok && f < min {
+				return fmt.Errorf("%s: must be >= %v", where(path), min)
+			}
+			if max, ok := number(schema["maximum"]); ok && f > max {
+				return fmt.Errorf("%s: must be <= %v", where(path), max)
+			}
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, _ := r.(string); name != "" {
+				if _, ok := obj[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", where(path), name)
+				}
+			}
+		}
+	}
+	// Manifests built in Go may use []string
+	if required, ok := schema["required"].([]string); ok {
+		for _, name := range required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", where(path), name)
+			}
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := props[name].(map[string]any)
+		if !ok {
+			if extra, ok := schema["additionalProperties"].(bool); ok && !extra {
+				return fmt.Errorf("%s: unexpected property %q", where(path), name)
+			}
+			continue
+		}
+		if err := validate(prop, obj[name], strings.TrimPrefix(path+"."+name, ".")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	case "number":
+		_, ok := number(v)
+		return ok
+	case "integer":
+		f, ok := number(v)
+		return ok && f == math.Trunc(f)
+	}
+	return true
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// number converts the numeric types found in decoded JSON and Go literals.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// normalize makes numbers comparable regardless of their Go type.
+func normalize(v any) any {
+	if f, ok := number(v); ok {
+		return f
+	}
+	return v
+}
+
+func where(path string) string {
+	if path == "" {
+		return "arguments"
+	}
+	return path
+}
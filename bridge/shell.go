@@ -1,96 +1,454 @@
 package bridge
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"path/filepath"
+	"io"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/lucci-labs/luccibot/bus"
+	"github.com/tetratelabs/wazero"
+)
+
+// DefaultSkillTimeout bounds the run time of skills when neither the
+// manifest nor the bridge sets a timeout.
+const DefaultSkillTimeout = 2 * time.Minute
+
+// maxStderr is how much of a skill's stderr is kept, from the end.
+const maxStderr = 1 << 10
+
+var (
+	errSkillCancelled = errors.New("cancelled")
+	errSkillTimeout   = errors.New("timed out")
 )
 
 // Bridge handles the execution of external skills/scripts.
 type Bridge struct {
+	// DefaultChain is sent to skills whose arguments name no chain.
+	DefaultChain string
+	// Account is the address of the active wallet account, sent to skills.
+	Account string
+	// Timeout bounds skills whose manifest sets none; zero selects
+	// DefaultSkillTimeout.
+	Timeout time.Duration
+	// Isolate runs skills in their own Linux namespaces, without network
+	// unless the manifest asks for it, and confines their filesystem with
+	// Landlock.
+	Isolate bool
+	// SkillConfig holds the settings of each skill by name, which WASM
+	// skills with the config permission can read.
+	SkillConfig map[string]map[string]string
+	// Secrets provides the secrets listed in skill manifests.
+	Secrets SecretSource
+	// Lock pins skills to the hash of their files; changed skills are
+	// refused. Nil disables the check.
+	Lock *Lockfile
+	// RequireApproval also refuses skills that are not pinned in Lock.
+	RequireApproval bool
+	// MaxConcurrent limits the number of skills running at once; zero
+	// selects DefaultMaxConcurrent.
+	MaxConcurrent int
+
 	hub       *bus.Hub
 	skillsDir string
+	registry  *Registry
+	runtimes  map[string]Runtime
+	goSkills  map[string]GoSkill
+	wasmCache wazero.CompilationCache
+	// supervisors tracks the goroutines supervising skill daemons.
+	supervisors sync.WaitGroup
+
+	// mu guards the worker pool (see pool.go) and running, the cancel
+	// functions of the running skills by Action RequestID.
+	mu      sync.Mutex
+	queue   []job
+	active  int
+	bySkill map[string]int
+	signing int
+	running map[string]context.CancelCauseFunc
+	daemons map[string]*daemon
+}
+
+// SecretSource reveals the named secrets for a run of a skill, if the user
+// approved them for it as pinned to pin in the lockfile. The vault's
+// SecretStore implements it.
+type SecretSource interface {
+	Reveal(skill, pin, requestID string, names []string) (map[string]string, error)
 }
 
-// NewBridge creates a new Bridge service.
+// NewBridge creates a new Bridge service. Call LoadSkills to discover the
+// skills in skillsDir.
 func NewBridge(hub *bus.Hub, skillsDir string) *Bridge {
 	return &Bridge{
-		hub:       hub,
-		skillsDir: skillsDir,
+		DefaultChain: "ethereum",
+		hub:          hub,
+		skillsDir:    skillsDir,
+		registry:     NewRegistry(),
+		runtimes:     defaultRuntimes(),
+		goSkills:     make(map[string]GoSkill),
+		wasmCache:    wazero.NewCompilationCache(),
+		bySkill:      make(map[string]int),
+		running:      make(map[string]context.CancelCauseFunc),
+		daemons:      make(map[string]*daemon),
 	}
 }
 
-// Start listens for Action requests and processes them.
+// LoadSkills registers every skill with a manifest in the skills directory.
+// Skills with invalid manifests are reported in the error and left out.
+func (b *Bridge) LoadSkills() error {
+	return b.registry.Scan(b.skillsDir)
+}
+
+// Registry returns the skills known to the bridge.
+func (b *Bridge) Registry() *Registry {
+	return b.registry
+}
+
+// Start launches the skill daemons and listens for Action requests, which
+// run on the worker pool. Running skills are killed and daemons shut down
+// when ctx is cancelled.
 func (b *Bridge) Start(ctx context.Context) {
+	for _, m := range b.registry.List() {
+		if m.Daemon {
+			b.startDaemon(ctx, m)
+		}
+	}
+
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case action := <-b.hub.ActionReq:
-				b.executeSkill(action)
+				b.hub.RecordAction(action)
+				b.submit(ctx, action)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case id := <-b.hub.SkillCancel:
+				b.Cancel(id)
 			}
 		}
 	}()
 }
 
-// executeSkill runs the external script and handles its output.
-func (b *Bridge) executeSkill(action bus.Action) {
-	// Construct the path to the skill script.
-	// Assuming TypeScript scripts run via 'bun'.
-	scriptPath := filepath.Join(b.skillsDir, action.SkillName)
-	
-	cmd := exec.Command("bun", append([]string{scriptPath}, action.Args...)...)
+// Wait blocks until the skill daemons have shut down, after the context
+// passed to Start is cancelled.
+func (b *Bridge) Wait() {
+	b.supervisors.Wait()
+}
 
-	// Capture stdout to get the transaction JSON.
-	output, err := cmd.Output()
-	if err != nil {
-		b.hub.Outbound <- bus.Event{
-			Type:    "ERROR",
-			Payload: fmt.Sprintf("Failed to execute skill %s: %v", action.SkillName, err),
+// timeout returns the run time limit of a skill.
+func (b *Bridge) timeout(m Manifest) time.Duration {
+	if m.Timeout > 0 {
+		return time.Duration(m.Timeout) * time.Second
+	}
+	if b.Timeout > 0 {
+		return b.Timeout
+	}
+	return DefaultSkillTimeout
+}
+
+// track registers the cancel function of a running skill and returns the
+// function that unregisters it.
+func (b *Bridge) track(id string, cancel context.CancelCauseFunc) func() {
+	b.mu.Lock()
+	b.running[id] = cancel
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.running, id)
+		b.mu.Unlock()
+	}
+}
+
+// prepare looks up the manifest of the action's skill and validates the
+// arguments against it.
+func (b *Bridge) prepare(action bus.Action) (Manifest, error) {
+	// Skill names come from the LLM; they are only ever looked up in the
+	// registry, never joined to a path.
+	if !skillName.MatchString(action.SkillName) {
+		return Manifest{}, fmt.Errorf("invalid skill name %q", action.SkillName)
+	}
+	manifest, ok := b.registry.Get(action.SkillName)
+	if !ok {
+		return Manifest{}, fmt.Errorf("skill %s is not installed", action.SkillName)
+	}
+	if err := b.verify(manifest); err != nil {
+		return Manifest{}, err
+	}
+	if err := ValidateArgs(manifest.Parameters, action.Args); err != nil {
+		return Manifest{}, fmt.Errorf("invalid arguments for skill %s: %w", action.SkillName, err)
+	}
+	return manifest, nil
+}
+
+// executeSkill runs the skill and handles its result. It returns once the
+// action has been answered, including any signature.
+func (b *Bridge) executeSkill(ctx context.Context, action bus.Action, manifest Manifest) {
+	chain, _ := action.Args["chain"].(string)
+	if chain == "" {
+		chain = b.DefaultChain
+	}
+	req := SkillRequest{
+		Version:   ProtocolVersion,
+		RequestID: action.RequestID,
+		Action:    manifest.Name,
+		Params:    action.Args,
+		Chain:     chain,
+		Account:   b.Account,
+	}
+	if len(manifest.Secrets) > 0 {
+		if b.Secrets == nil {
+			b.fail(action, fmt.Errorf("skill %s needs secrets, but no secret store is available", action.SkillName))
+			return
 		}
+		// verify has checked the skill's files against its pin, if any.
+		var pin Pin
+		if b.Lock != nil {
+			pin, _ = b.Lock.Pinned(manifest.Name)
+		}
+		secrets, err := b.Secrets.Reveal(manifest.Name, pin.SHA256, action.RequestID, manifest.Secrets)
+		if err != nil {
+			b.fail(action, fmt.Errorf("secrets for skill %s unavailable: %w", action.SkillName, err))
+			return
+		}
+		req.Secrets = secrets
+		b.hub.Outbound <- bus.LogEvent("Injected secrets %s into skill %s", strings.Join(manifest.Secrets, ", "), action.SkillName).WithParent(action.RequestID)
+	}
+
+	// The skill is stopped on timeout, on Cancel and when the bridge stops.
+	limit := b.timeout(manifest)
+	runCtx, cancel := context.WithCancelCause(ctx)
+	runCtx, cancelTimeout := context.WithTimeoutCause(runCtx, limit, errSkillTimeout)
+	defer cancelTimeout()
+	defer cancel(nil)
+	untrack := func() {}
+	if action.RequestID != "" {
+		untrack = b.track(action.RequestID, cancel)
+		defer untrack()
+	}
+
+	progress := func(step string) {
+		b.hub.Outbound <- bus.LogEvent("[%s] %s", action.SkillName, step).WithParent(action.RequestID)
+	}
+	var msg SkillMessage
+	var err error
+	switch runtime := manifest.RuntimeName(); {
+	case manifest.Daemon:
+		msg, err = b.runDaemon(runCtx, manifest, req, progress)
+	case runtime == RuntimeGo:
+		msg, err = b.runGo(runCtx, manifest, req, progress)
+	case runtime == RuntimeWasm:
+		msg, err = b.runWasm(runCtx, action, manifest, req, progress)
+	default:
+		msg, err = b.runProcess(runCtx, manifest, req, progress)
+	}
+	// Once the skill is done there is nothing left to cancel.
+	untrack()
+
+	if runCtx.Err() != nil {
+		var detail string
+		if err != nil {
+			detail = ": " + err.Error()
+		}
+		if errors.Is(context.Cause(runCtx), errSkillTimeout) {
+			err = fmt.Errorf("skill %s timed out after %s%s", action.SkillName, limit, detail)
+		} else {
+			err = fmt.Errorf("skill %s %v%s", action.SkillName, context.Cause(runCtx), detail)
+		}
+	}
+	if err != nil {
+		b.fail(action, err)
 		return
 	}
 
-	// In a real scenario, we might want to validate that 'output' is valid JSON
-	// or specific transaction structure before requesting a signature.
-	// For this architecture, we treat the output as the Transaction Data.
-	
+	// Info results go back to the agent; only transactions go to the Vault.
+	switch msg.Type {
+	case MessageError:
+		b.fail(action, fmt.Errorf("skill %s failed: %s", action.SkillName, msg.Message))
+		return
+	case MessageInfo:
+		b.reply(action, bus.ActionResult{Output: msg.Data})
+		return
+	}
+	if manifest.Output != OutputTransaction {
+		b.fail(action, fmt.Errorf("skill %s is not allowed to request signatures", action.SkillName))
+		return
+	}
+	output := []byte(msg.Tx)
+	sig, err := b.sign(ctx, action, output)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		b.fail(action, err)
+		return
+	}
+	b.reply(action, bus.ActionResult{Output: output, Signature: sig})
+}
+
+// sign asks the vault to sign tx for the action and waits for the
+// signature; the skill keeps its slot in the pool meanwhile.
+func (b *Bridge) sign(ctx context.Context, action bus.Action, tx []byte) ([]byte, error) {
 	// Create a channel to receive the signature response.
 	respChan := make(chan bus.SignResponse, 1)
 
 	// Send request to Vault via the Hub.
-	b.hub.SignReq <- bus.SignRequest{
-		TxData:       output,
+	req := bus.SignRequest{
+		RequestID:    bus.NewRequestID(),
+		ParentID:     action.RequestID,
+		TxData:       tx,
 		ResponseChan: respChan,
 	}
+	b.announce(req.RequestID, req.ParentID, "Requesting signature for %s", action.SkillName)
+	select {
+	case b.hub.SignReq <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
-	// Wait for the signature.
-	go func() {
-		resp := <-respChan
-		if resp.Error != nil {
-			b.hub.Outbound <- bus.Event{
-				Type:    "ERROR",
-				Payload: fmt.Sprintf("Signing failed: %v", resp.Error),
-			}
-			return
-		}
+	var resp bus.SignResponse
+	select {
+	case resp = <-respChan:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	b.hub.RecordSignResponse(resp)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("signing failed: %w", resp.Error)
+	}
 
-		b.hub.Outbound <- bus.Event{
-			Type: "LOG",
-			Payload: fmt.Sprintf("Transaction signed successfully. Signature: %s", string(resp.Signature)),
-		}
-		
-		// Here we would likely broadcast the signed transaction or return it to the UI.
-		b.hub.Outbound <- bus.Event{
-			Type: "TX_SIGNED",
-			Payload: map[string]string{
-				"raw_tx":    string(output),
-				"signature": string(resp.Signature),
-			},
+	b.hub.Outbound <- bus.NewEvent(bus.TxSignedPayload{
+		Skill:     action.SkillName,
+		RawTx:     string(tx),
+		Signature: string(resp.Signature),
+		TxHash:    resp.TxHash,
+	}).WithParent(req.RequestID)
+	return resp.Signature, nil
+}
+
+// runProcess runs a skill as a sandboxed subprocess of its runtime and
+// returns its final message. When ctx ends early, the error carries the
+// skill's stderr.
+func (b *Bridge) runProcess(ctx context.Context, manifest Manifest, req SkillRequest, progress func(string)) (SkillMessage, error) {
+	name := manifest.Name
+	input, err := encodeRequest(req)
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to encode request for skill %s: %w", name, err)
+	}
+	argv, err := b.command(manifest)
+	if err != nil {
+		return SkillMessage{}, err
+	}
+
+	// The request goes to stdin; the skill answers with JSON lines on stdout.
+	limits := manifest.Limits.withDefaults()
+	cmd := skillCommand(ctx, limits, argv[0], argv[1:]...)
+	setProcessGroup(cmd)
+	cleanup, err := b.sandbox(cmd, manifest)
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to sandbox skill %s: %w", name, err)
+	}
+	defer cleanup()
+	cmd.Stdin = bytes.NewReader(input)
+	stderr := &tailBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to execute skill %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to execute skill %s: %w", name, err)
+	}
+
+	msg, readErr := readMessages(stdout, progress)
+	if readErr != nil {
+		// Drain stdout so that Wait can return.
+		io.Copy(io.Discard, stdout)
+	}
+	waitErr := cmd.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		// The caller reports the timeout or cancellation; stderr may explain it.
+		if tail := strings.TrimSpace(stderr.String()); tail != "" {
+			return SkillMessage{}, errors.New(tail)
 		}
-	}()
+		return SkillMessage{}, nil
+	case readErr == nil && msg.Type == MessageError:
+		return msg, nil
+	case limitViolation(waitErr, limits) != "":
+		return SkillMessage{}, fmt.Errorf("skill %s %s%s", name, limitViolation(waitErr, limits), stderrTail(stderr.String()))
+	case waitErr != nil:
+		return SkillMessage{}, fmt.Errorf("failed to execute skill %s: %w%s", name, waitErr, stderrTail(stderr.String()))
+	case readErr != nil:
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %w", name, readErr)
+	}
+	return msg, nil
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	if t.truncated {
+		return "..." + string(t.buf)
+	}
+	return string(t.buf)
+}
+
+// stderrTail formats the captured stderr of a skill for an error message.
+func stderrTail(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
+	}
+	return ": " + stderr
+}
+
+// fail reports a skill failure to the UI and to the requester of the action.
+func (b *Bridge) fail(action bus.Action, err error) {
+	b.hub.Outbound <- bus.ErrorEvent("%v", err).WithParent(action.RequestID)
+	b.reply(action, bus.ActionResult{Error: err})
+}
+
+// announce logs a step that is identified by requestID, such as an action
+// or a sign request.
+func (b *Bridge) announce(requestID, parentID, format string, args ...any) {
+	ev := bus.LogEvent(format, args...)
+	ev.RequestID = requestID
+	b.hub.Outbound <- ev.WithParent(parentID)
+}
+
+// reply delivers the result to the action's ResultChan, if the requester asked for one.
+func (b *Bridge) reply(action bus.Action, result bus.ActionResult) {
+	result.RequestID = action.RequestID
+	b.hub.RecordActionResult(result)
+	if action.ResultChan != nil {
+		action.ResultChan <- result
+	}
 }
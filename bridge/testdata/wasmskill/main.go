@@ -0,0 +1,64 @@
+//go:build wasip1
+
+// Command wasmskill is the WASM skill used by the bridge tests. It calls the
+// host function named by the "mode" parameter with the "arg" parameter and
+// returns the host's result.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+//go:wasmimport luccibot http_request
+func httpRequest(ptr, size uint32) uint32
+
+//go:wasmimport luccibot config_get
+func configGet(ptr, size uint32) uint32
+
+//go:wasmimport luccibot sign_request
+func signRequest(ptr, size uint32) uint32
+
+//go:wasmimport luccibot result_read
+func resultRead(ptr, size uint32) uint32
+
+func call(f func(ptr, size uint32) uint32, arg string) json.RawMessage {
+	in := []byte(arg)
+	n := f(uint32(uintptr(unsafe.Pointer(unsafe.SliceData(in)))), uint32(len(in)))
+	out := make([]byte, n)
+	resultRead(uint32(uintptr(unsafe.Pointer(unsafe.SliceData(out)))), n)
+	return out
+}
+
+func main() {
+	var req struct {
+		Params struct {
+			Mode string `json:"mode"`
+			Arg  string `json:"arg"`
+		} `json:"params"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(`{"version":1,"type":"progress","message":"calling ` + req.Params.Mode + `"}`)
+
+	var result json.RawMessage
+	switch req.Params.Mode {
+	case "http":
+		result = call(httpRequest, req.Params.Arg)
+	case "config":
+		result = call(configGet, req.Params.Arg)
+	case "sign":
+		result = call(signRequest, req.Params.Arg)
+	case "spin":
+		for {
+		}
+	default:
+		result = json.RawMessage(`{}`)
+	}
+	out, _ := json.Marshal(map[string]any{"version": 1, "type": "info", "data": result})
+	fmt.Println(string(out))
+}
@@ -0,0 +1,298 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lucci-labs/luccibot/bus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// hostModule is the module WASM skills import host functions from.
+//
+// Every host function takes a pointer and length of its input in the
+// guest's memory and returns the length of its JSON result, which the guest
+// copies into its memory with result_read(ptr, cap). Failures are results
+// of the form {"error": "..."}.
+//
+//	http_request(req)  {"method", "url", "headers", "body"} -> {"status", "headers", "body"}
+//	config_get(key)    -> {"value"}
+//	sign_request(tx)   -> {"signature"}
+const hostModule = "luccibot"
+
+// maxHTTPBody bounds the response bodies returned to WASM skills.
+const maxHTTPBody = 1 << 20
+
+// wasmHost holds the state of the host functions of one WASM skill run.
+type wasmHost struct {
+	b        *Bridge
+	action   bus.Action
+	manifest Manifest
+	// result is the output of the last host call, read by result_read.
+	result []byte
+}
+
+// grants returns the host functions the manifest grants.
+func (h *wasmHost) grants() map[string]func(context.Context, []byte) any {
+	g := map[string]func(context.Context, []byte) any{}
+	if h.manifest.HasPermission(PermissionNetwork) && len(h.manifest.Hosts) > 0 {
+		g["http_request"] = h.httpRequest
+	}
+	if h.manifest.HasPermission(PermissionConfig) {
+		g["config_get"] = h.configGet
+	}
+	if h.manifest.Output == OutputTransaction {
+		g["sign_request"] = h.signRequest
+	}
+	return g
+}
+
+// runWasm runs a WASM skill under WASI with the host functions granted by
+// its manifest. The request goes to stdin and the skill answers on stdout,
+// as a skill process would.
+func (b *Bridge) runWasm(ctx context.Context, action bus.Action, manifest Manifest, req SkillRequest, progress func(string)) (SkillMessage, error) {
+	name := manifest.Name
+	bin, err := os.ReadFile(manifest.path())
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to load skill %s: %w", name, err)
+	}
+	input, err := encodeRequest(req)
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to encode request for skill %s: %w", name, err)
+	}
+
+	limits := manifest.Limits.withDefaults()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(min(limits.Memory*16, 65536))).
+		WithCompilationCache(b.wasmCache))
+	defer r.Close(context.Background())
+
+	compiled, err := r.CompileModule(ctx, bin)
+	if err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to compile skill %s: %w", name, err)
+	}
+
+	host := &wasmHost{b: b, action: action, manifest: manifest}
+	grants := host.grants()
+	if err := checkImports(compiled, grants); err != nil {
+		return SkillMessage{}, fmt.Errorf("skill %s: %w", name, err)
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to start skill %s: %w", name, err)
+	}
+	if err := host.instantiate(ctx, r, grants); err != nil {
+		return SkillMessage{}, fmt.Errorf("failed to start skill %s: %w", name, err)
+	}
+
+	stdout, stdoutW := io.Pipe()
+	stderr := &tailBuffer{max: maxStderr}
+	config := wazero.NewModuleConfig().
+		WithName(name).
+		WithArgs(name).
+		WithStdin(strings.NewReader(string(input))).
+		WithStdout(stdoutW).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+	for _, key := range append(append([]string{}, baseEnv...), manifest.Env...) {
+		if v, ok := os.LookupEnv(key); ok {
+			config = config.WithEnv(key, v)
+		}
+	}
+	if manifest.HasPermission(PermissionFilesystem) {
+		dir, err := os.MkdirTemp("", "luccibot-"+name+"-")
+		if err != nil {
+			return SkillMessage{}, fmt.Errorf("failed to sandbox skill %s: %w", name, err)
+		}
+		defer os.RemoveAll(dir)
+		config = config.WithFSConfig(wazero.NewFSConfig().WithDirMount(dir, "/"))
+	}
+
+	type read struct {
+		msg SkillMessage
+		err error
+	}
+	done := make(chan read, 1)
+	go func() {
+		msg, err := readMessages(stdout, progress)
+		if err != nil {
+			// Drain stdout so that the module does not block.
+			io.Copy(io.Discard, stdout)
+		}
+		done <- read{msg, err}
+	}()
+
+	_, runErr := r.InstantiateModule(ctx, compiled, config)
+	stdoutW.Close()
+	out := <-done
+
+	switch {
+	case ctx.Err() != nil:
+		// The caller reports the timeout or cancellation; stderr may explain it.
+		if tail := strings.TrimSpace(stderr.String()); tail != "" {
+			return SkillMessage{}, errors.New(tail)
+		}
+		return SkillMessage{}, nil
+	case out.err == nil && out.msg.Type == MessageError:
+		return out.msg, nil
+	case runErr != nil:
+		return SkillMessage{}, fmt.Errorf("failed to execute skill %s: %w%s", name, runErr, stderrTail(stderr.String()))
+	case out.err != nil:
+		return SkillMessage{}, fmt.Errorf("invalid output from skill %s: %w", name, out.err)
+	}
+	return out.msg, nil
+}
+
+// checkImports refuses modules that import anything but WASI and the
+// granted host functions.
+func checkImports(compiled wazero.CompiledModule, grants map[string]func(context.Context, []byte) any) error {
+	for _, f := range compiled.ImportedFunctions() {
+		module, name, _ := f.Import()
+		switch {
+		case module == wasi_snapshot_preview1.ModuleName:
+		case module == hostModule && name == "result_read":
+		case module == hostModule:
+			if _, ok := grants[name]; !ok {
+				return fmt.Errorf("imports %s.%s, which its manifest does not grant", module, name)
+			}
+		default:
+			return fmt.Errorf("imports unknown module %q", module)
+		}
+	}
+	return nil
+}
+
+// instantiate exports the granted host functions and result_read.
+func (h *wasmHost) instantiate(ctx context.Context, r wazero.Runtime, grants map[string]func(context.Context, []byte) any) error {
+	builder := r.NewHostModuleBuilder(hostModule)
+	names := make([]string, 0, len(grants))
+	for name := range grants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		call := grants[name]
+		builder.NewFunctionBuilder().
+			WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) uint32 {
+				in, ok := m.Memory().Read(ptr, size)
+				var result any = map[string]string{"error": "input out of memory bounds"}
+				if ok {
+					result = call(ctx, append([]byte(nil), in...))
+				}
+				h.result, _ = json.Marshal(result)
+				return uint32(len(h.result))
+			}).
+			Export(name)
+	}
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) uint32 {
+			n := min(size, uint32(len(h.result)))
+			if !m.Memory().Write(ptr, h.result[:n]) {
+				return 0
+			}
+			return n
+		}).
+		Export("result_read")
+	_, err := builder.Instantiate(ctx)
+	return err
+}
+
+// wasmError is the result of a failed host call.
+func wasmError(format string, args ...any) any {
+	return map[string]string{"error": fmt.Sprintf(format, args...)}
+}
+
+func (h *wasmHost) httpRequest(ctx context.Context, in []byte) any {
+	var req struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+	if err := json.Unmarshal(in, &req); err != nil {
+		return wasmError("invalid request: %v", err)
+	}
+	if err := h.allowURL(req.URL); err != nil {
+		return wasmError("%v", err)
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, strings.NewReader(req.Body))
+	if err != nil {
+		return wasmError("invalid request: %v", err)
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	client := &http.Client{
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			return h.allowURL(r.URL.String())
+		},
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return wasmError("%v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	if err != nil {
+		return wasmError("%v", err)
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		headers[k] = resp.Header.Get(k)
+	}
+	return map[string]any{"status": resp.StatusCode, "headers": headers, "body": string(body)}
+}
+
+// allowURL checks that a URL points to a host listed in the manifest.
+func (h *wasmHost) allowURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range h.manifest.Hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed", host)
+}
+
+func (h *wasmHost) configGet(ctx context.Context, in []byte) any {
+	key := string(in)
+	value, ok := h.b.SkillConfig[h.manifest.Name][key]
+	if !ok {
+		return wasmError("config %q is not set", key)
+	}
+	return map[string]string{"value": value}
+}
+
+func (h *wasmHost) signRequest(ctx context.Context, in []byte) any {
+	sig, err := h.b.sign(ctx, h.action, in)
+	if err != nil {
+		return wasmError("%v", err)
+	}
+	return map[string]string{"signature": string(sig)}
+}
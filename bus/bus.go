@@ -1,30 +1,86 @@
 package bus
 
-// Event represents a generic message for UI/System communication.
+import "sync"
+
+// Event represents a message for UI/System communication. Type always
+// matches Payload.EventType(); build events with NewEvent. See events.go for
+// the catalogue of event types and their payloads.
+//
+// RequestID identifies the event; ParentID is the RequestID of the user
+// message, Action or SignRequest that caused it, so that everything
+// triggered by a prompt can be traced back to it.
 type Event struct {
-	Type    string `json:"type"`
-	Payload any    `json:"payload"`
+	Type      EventType `json:"type"`
+	Payload   Payload   `json:"payload"`
+	RequestID string    `json:"request_id,omitempty"`
+	ParentID  string    `json:"parent_id,omitempty"`
 }
 
 // Action represents a request to execute a skill.
 type Action struct {
-	SkillName string   `json:"skill_name"`
-	Args      []string `json:"args"`
+	RequestID string         `json:"request_id"`
+	ParentID  string         `json:"parent_id,omitempty"`
+	SkillName string         `json:"skill_name"`
+	Args      map[string]any `json:"args"`
+	// ResultChan, when set, receives the outcome of the skill execution.
+	ResultChan chan<- ActionResult `json:"-"`
+}
+
+// ActionResult represents the outcome of an executed Action.
+type ActionResult struct {
+	RequestID string `json:"request_id"`
+	Output    []byte `json:"output,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     error  `json:"-"`
 }
 
 // SignRequest represents a request to sign a transaction.
 type SignRequest struct {
-	TxData       []byte
-	ResponseChan chan<- SignResponse
+	RequestID    string              `json:"request_id"`
+	ParentID     string              `json:"parent_id,omitempty"`
+	TxData       []byte              `json:"tx_data"`
+	ResponseChan chan<- SignResponse `json:"-"`
 }
 
 // SignResponse represents the result of a signing operation.
 type SignResponse struct {
-	Signature []byte
-	Error     error
+	RequestID string `json:"request_id"`
+	// Signature is the signed transaction as 0x-prefixed hex, ready to
+	// broadcast.
+	Signature []byte `json:"signature,omitempty"`
+	// TxHash is the hash of the signed transaction.
+	TxHash string `json:"tx_hash,omitempty"`
+	Error  error  `json:"-"`
 }
 
-// Hub manages the centralized channels for the application.
+// VaultAction is what a VaultControl asks of the vault.
+type VaultAction string
+
+const (
+	VaultUnlock VaultAction = "unlock"
+	VaultLock   VaultAction = "lock"
+)
+
+// VaultControl asks the vault to unlock with a passphrase typed in the TUI,
+// or to lock. The passphrase is never serialized.
+type VaultControl struct {
+	Action     VaultAction `json:"action"`
+	Passphrase string      `json:"-"`
+	// Reason tells why the vault is asked to lock.
+	Reason VaultReason `json:"reason,omitempty"`
+	// ResponseChan, when set, receives the outcome.
+	ResponseChan chan<- error `json:"-"`
+}
+
+// SignDecision is the user's answer to a "sign_approval" event.
+type SignDecision struct {
+	SignRequestID string `json:"sign_request_id"`
+	Approved      bool   `json:"approved"`
+}
+
+// Hub manages the centralized channels for the application. Besides the
+// point-to-point channels it fans events out to any number of subscribers;
+// see Subscribe and Publish.
 type Hub struct {
 	// Inbound: User requests from the TUI to the Orchestrator.
 	Inbound chan Event
@@ -34,14 +90,33 @@ type Hub struct {
 	ActionReq chan Action
 	// SignReq: Bridge/Orchestrator requests a signature from the Vault.
 	SignReq chan SignRequest
+	// SkillCancel: Orchestrator asks the Bridge to kill the skill running for
+	// an Action, by RequestID; an empty ID cancels every running skill.
+	SkillCancel chan string
+	// VaultCtl: TUI asks the Vault to unlock or lock.
+	VaultCtl chan VaultControl
+	// SignDecisions: TUI approves or rejects a signature the Vault holds.
+	SignDecisions chan SignDecision
+
+	// Subscribers of published events, see pubsub.go.
+	subMu sync.RWMutex
+	subs  map[*Subscription]struct{}
+
+	// recorder observes every message, see record.go.
+	recMu    sync.RWMutex
+	recorder func(Record)
 }
 
 // NewHub initializes and returns a new Hub with buffered channels.
 func NewHub() *Hub {
 	return &Hub{
-		Inbound:   make(chan Event, 10),
-		Outbound:  make(chan Event, 10),
-		ActionReq: make(chan Action, 10),
-		SignReq:   make(chan SignRequest, 10),
+		Inbound:       make(chan Event, 10),
+		Outbound:      make(chan Event, 10),
+		ActionReq:     make(chan Action, 10),
+		SignReq:       make(chan SignRequest, 10),
+		SkillCancel:   make(chan string, 10),
+		VaultCtl:      make(chan VaultControl, 10),
+		SignDecisions: make(chan SignDecision, 10),
+		subs:          make(map[*Subscription]struct{}),
 	}
 }
@@ -0,0 +1,335 @@
+package bus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EventType identifies the kind of an Event and the type of its payload.
+type EventType string
+
+// Inbound events, sent by the TUI to the agent.
+const (
+	// EventUserMessage carries a chat message typed by the user.
+	EventUserMessage EventType = "user_message"
+	// EventCommand carries a slash command such as "/new".
+	EventCommand EventType = "command"
+	// EventCancel asks the agent to abort the running generation.
+	EventCancel EventType = "cancel"
+	// EventCancelSkill asks the bridge to kill a running skill.
+	EventCancelSkill EventType = "cancel_skill"
+)
+
+// Outbound events, sent by the agent, bridge and vault to the TUI.
+const (
+	EventLog           EventType = "log"
+	EventError         EventType = "error"
+	EventTrace         EventType = "trace"
+	EventResponse      EventType = "response"
+	EventThinking      EventType = "thinking"
+	EventResponseDelta EventType = "response_delta"
+	EventResponseDone  EventType = "response_done"
+	EventUsage         EventType = "usage"
+	EventTxSigned      EventType = "tx_signed"
+	EventSkillStatus   EventType = "skill_status"
+	EventVaultStatus   EventType = "vault_status"
+	EventSignApproval  EventType = "sign_approval"
+	EventSignDecision  EventType = "sign_decision"
+)
+
+// Payload is implemented by the payload struct of every event type.
+type Payload interface {
+	EventType() EventType
+}
+
+// NewEvent wraps a payload in an Event of the matching type, with a new
+// RequestID.
+func NewEvent(p Payload) Event {
+	return Event{Type: p.EventType(), Payload: p, RequestID: NewRequestID()}
+}
+
+// NewRequestID returns a random ID for an Event, Action or SignRequest.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req-" + hex.EncodeToString(b)
+}
+
+// WithParent returns a copy of the event caused by parentID.
+func (e Event) WithParent(parentID string) Event {
+	e.ParentID = parentID
+	return e
+}
+
+// LogEvent returns a log event with a formatted message.
+func LogEvent(format string, args ...any) Event {
+	return NewEvent(LogPayload{Message: fmt.Sprintf(format, args...)})
+}
+
+// ErrorEvent returns an error event with a formatted message.
+func ErrorEvent(format string, args ...any) Event {
+	return NewEvent(ErrorPayload{Message: fmt.Sprintf(format, args...)})
+}
+
+// UserMessagePayload is the payload of "user_message" events.
+type UserMessagePayload struct {
+	Text string `json:"text"`
+}
+
+// CommandPayload is the payload of "command" events. Text is the full
+// command line including the leading slash.
+type CommandPayload struct {
+	Text string `json:"text"`
+}
+
+// CancelPayload is the (empty) payload of "cancel" events.
+type CancelPayload struct{}
+
+// CancelSkillPayload is the payload of "cancel_skill" events. ActionID is
+// the RequestID of the Action to cancel; empty cancels every running skill.
+type CancelSkillPayload struct {
+	ActionID string `json:"action_id,omitempty"`
+}
+
+// LogPayload is the payload of "log" events.
+type LogPayload struct {
+	Message string `json:"message"`
+}
+
+// ErrorPayload is the payload of "error" events.
+type ErrorPayload struct {
+	Message string `json:"message"`
+}
+
+// TracePayload is the payload of "trace" events, reporting a step taken by
+// a specialist agent.
+type TracePayload struct {
+	Specialist string `json:"specialist"`
+	Step       string `json:"step"`
+}
+
+// ResponsePayload is the payload of "response" events: a complete,
+// non-streamed reply such as the output of a slash command.
+type ResponsePayload struct {
+	Text string `json:"text"`
+}
+
+// ThinkingPayload is the payload of "thinking" events, sent when a
+// generation starts. MessageID ties the "thinking", "response_delta" and
+// "response_done" events of a single generation to the same message bubble.
+type ThinkingPayload struct {
+	MessageID string `json:"message_id"`
+}
+
+// ResponseDeltaPayload is the payload of "response_delta" events, carrying
+// the next piece of a streamed answer.
+type ResponseDeltaPayload struct {
+	MessageID string `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+// ResponseDonePayload is the payload of "response_done" events. Text is the
+// full answer; it is empty when the generation failed or was cancelled.
+type ResponseDonePayload struct {
+	MessageID string `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+// UsageStats is the payload of "usage" events, published after every LLM
+// response so the TUI can show token usage and spend.
+type UsageStats struct {
+	Model         string  `json:"model"`
+	SessionTokens int     `json:"session_tokens"`
+	SessionCost   float64 `json:"session_cost"`
+	DayCost       float64 `json:"day_cost"`
+	// ContextTokens is the size of the latest request and its reply;
+	// ContextWindow is zero when the model's window is unknown.
+	ContextTokens int `json:"context_tokens"`
+	ContextWindow int `json:"context_window"`
+}
+
+// TxSignedPayload is the payload of "tx_signed" events, sent once the vault
+// has signed a transaction produced by a skill.
+type TxSignedPayload struct {
+	Skill     string `json:"skill"`
+	RawTx     string `json:"raw_tx"`
+	Signature string `json:"signature"`
+	TxHash    string `json:"tx_hash,omitempty"`
+}
+
+// SkillStatus is the state of an Action in the bridge's worker pool.
+type SkillStatus string
+
+const (
+	SkillQueued   SkillStatus = "queued"
+	SkillRunning  SkillStatus = "running"
+	SkillFinished SkillStatus = "finished"
+)
+
+// SkillStatusPayload is the payload of "skill_status" events, sent by the
+// bridge when an Action is queued, starts running and finishes. Queued and
+// Running count the bridge's actions after the change.
+type SkillStatusPayload struct {
+	ActionID string      `json:"action_id"`
+	Skill    string      `json:"skill"`
+	Status   SkillStatus `json:"status"`
+	Queued   int         `json:"queued"`
+	Running  int         `json:"running"`
+}
+
+// VaultReason tells why the vault locked.
+type VaultReason string
+
+const (
+	// VaultStartup: the vault starts locked.
+	VaultStartup VaultReason = "startup"
+	// VaultIdle: nothing was signed for the idle timeout.
+	VaultIdle VaultReason = "idle"
+	// VaultSuspend: the terminal was suspended.
+	VaultSuspend VaultReason = "suspend"
+	// VaultUser: the user locked the vault.
+	VaultUser VaultReason = "user"
+	// VaultSignRejected: a sign request was rejected because the vault is
+	// locked; the TUI asks for the passphrase.
+	VaultSignRejected VaultReason = "sign_rejected"
+)
+
+// VaultStatusPayload is the payload of "vault_status" events, sent by the
+// vault when it locks or unlocks. Account is the address it signs with
+// while unlocked.
+type VaultStatusPayload struct {
+	Locked  bool        `json:"locked"`
+	Reason  VaultReason `json:"reason,omitempty"`
+	Account string      `json:"account,omitempty"`
+}
+
+// SignApprovalPayload is the payload of "sign_approval" events, sent by the
+// vault for every SignRequest. The transaction is signed only once the user
+// approves it with a SignDecision on Hub.SignDecisions; without one by
+// Expires it is rejected. Amount is in Token, a native coin symbol or the
+// address of an ERC-20 contract whose base units Amount counts; GasCost is
+// the most the transaction can spend on gas, in GasToken.
+type SignApprovalPayload struct {
+	SignRequestID string    `json:"sign_request_id"`
+	From          string    `json:"from"`
+	Chain         string    `json:"chain"`
+	ChainID       string    `json:"chain_id"`
+	To            string    `json:"to,omitempty"`
+	Amount        string    `json:"amount"`
+	Token         string    `json:"token"`
+	GasCost       string    `json:"gas_cost"`
+	GasToken      string    `json:"gas_token"`
+	Calldata      string    `json:"calldata,omitempty"`
+	Expires       time.Time `json:"expires"`
+}
+
+// SignDecisionPayload is the payload of "sign_decision" events, sent by the
+// vault once a SignRequest is approved, rejected or timed out.
+type SignDecisionPayload struct {
+	SignRequestID string `json:"sign_request_id"`
+	Approved      bool   `json:"approved"`
+	TimedOut      bool   `json:"timed_out,omitempty"`
+}
+
+func (UserMessagePayload) EventType() EventType   { return EventUserMessage }
+func (CommandPayload) EventType() EventType       { return EventCommand }
+func (CancelPayload) EventType() EventType        { return EventCancel }
+func (CancelSkillPayload) EventType() EventType   { return EventCancelSkill }
+func (LogPayload) EventType() EventType           { return EventLog }
+func (ErrorPayload) EventType() EventType         { return EventError }
+func (TracePayload) EventType() EventType         { return EventTrace }
+func (ResponsePayload) EventType() EventType      { return EventResponse }
+func (ThinkingPayload) EventType() EventType      { return EventThinking }
+func (ResponseDeltaPayload) EventType() EventType { return EventResponseDelta }
+func (ResponseDonePayload) EventType() EventType  { return EventResponseDone }
+func (UsageStats) EventType() EventType           { return EventUsage }
+func (TxSignedPayload) EventType() EventType      { return EventTxSigned }
+func (SkillStatusPayload) EventType() EventType   { return EventSkillStatus }
+func (VaultStatusPayload) EventType() EventType   { return EventVaultStatus }
+func (SignApprovalPayload) EventType() EventType  { return EventSignApproval }
+func (SignDecisionPayload) EventType() EventType  { return EventSignDecision }
+
+// payloadDecoders maps every event type to a decoder for its payload.
+var payloadDecoders = map[EventType]func(json.RawMessage) (Payload, error){
+	EventUserMessage:   decodePayload[UserMessagePayload],
+	EventCommand:       decodePayload[CommandPayload],
+	EventCancel:        decodePayload[CancelPayload],
+	EventCancelSkill:   decodePayload[CancelSkillPayload],
+	EventLog:           decodePayload[LogPayload],
+	EventError:         decodePayload[ErrorPayload],
+	EventTrace:         decodePayload[TracePayload],
+	EventResponse:      decodePayload[ResponsePayload],
+	EventThinking:      decodePayload[ThinkingPayload],
+	EventResponseDelta: decodePayload[ResponseDeltaPayload],
+	EventResponseDone:  decodePayload[ResponseDonePayload],
+	EventUsage:         decodePayload[UsageStats],
+	EventTxSigned:      decodePayload[TxSignedPayload],
+	EventSkillStatus:   decodePayload[SkillStatusPayload],
+	EventVaultStatus:   decodePayload[VaultStatusPayload],
+	EventSignApproval:  decodePayload[SignApprovalPayload],
+	EventSignDecision:  decodePayload[SignDecisionPayload],
+}
+
+func decodePayload[T Payload](data json.RawMessage) (Payload, error) {
+	var p T
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// EventTypes returns every known event type, sorted.
+func EventTypes() []EventType {
+	types := make([]EventType, 0, len(payloadDecoders))
+	for t := range payloadDecoders {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// MarshalJSON encodes the event as {"type": ..., "payload": ...}. It fails
+// if Type does not match the payload.
+func (e Event) MarshalJSON() ([]byte, error) {
+	if e.Payload == nil {
+		return nil, fmt.Errorf("event %q has no payload", e.Type)
+	}
+	if e.Type != e.Payload.EventType() {
+		return nil, fmt.Errorf("event type %q does not match %T payload", e.Type, e.Payload)
+	}
+	type wire Event
+	return json.Marshal(wire(e))
+}
+
+// UnmarshalJSON decodes the payload into the concrete struct registered for
+// the event's type tag.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type      EventType       `json:"type"`
+		Payload   json.RawMessage `json:"payload"`
+		RequestID string          `json:"request_id"`
+		ParentID  string          `json:"parent_id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	decode, ok := payloadDecoders[raw.Type]
+	if !ok {
+		return fmt.Errorf("unknown event type %q", raw.Type)
+	}
+	p, err := decode(raw.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid %s payload: %w", raw.Type, err)
+	}
+	e.Type = raw.Type
+	e.Payload = p
+	e.RequestID = raw.RequestID
+	e.ParentID = raw.ParentID
+	return nil
+}
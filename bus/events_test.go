@@ -0,0 +1,70 @@
+package bus
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventJSONRoundTrip(t *testing.T) {
+	events := []Event{
+		NewEvent(UserMessagePayload{Text: "hi"}),
+		NewEvent(CommandPayload{Text: "/new"}),
+		NewEvent(CancelPayload{}),
+		NewEvent(CancelSkillPayload{ActionID: "req-1"}),
+		LogEvent("step %d", 1),
+		ErrorEvent("failed: %v", "boom"),
+		NewEvent(TracePayload{Specialist: "trading", Step: "done"}),
+		NewEvent(ResponsePayload{Text: "ok"}),
+		NewEvent(ThinkingPayload{MessageID: "msg-1"}),
+		NewEvent(ResponseDeltaPayload{MessageID: "msg-1", Text: "Hel"}),
+		NewEvent(ResponseDonePayload{MessageID: "msg-1", Text: "Hello"}),
+		NewEvent(UsageStats{Model: "gpt-4o", SessionTokens: 10, SessionCost: 0.5}),
+		NewEvent(TxSignedPayload{Skill: "swap.ts", RawTx: "0x01", Signature: "0xsig", TxHash: "0xhash"}).WithParent("req-1"),
+		NewEvent(SkillStatusPayload{ActionID: "req-2", Skill: "get_balance", Status: SkillQueued, Queued: 1}),
+		NewEvent(VaultStatusPayload{Locked: true, Reason: VaultSignRejected}).WithParent("req-3"),
+		NewEvent(SignApprovalPayload{SignRequestID: "req-3", From: "0xabc", Chain: "Base", ChainID: "8453", To: "0xdef", Amount: "1.5", Token: "ETH", GasCost: "0.0001", GasToken: "ETH", Expires: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}).WithParent("req-3"),
+		NewEvent(SignDecisionPayload{SignRequestID: "req-3", TimedOut: true}).WithParent("req-3"),
+	}
+
+	// Every event type is covered
+	if len(events) != len(EventTypes()) {
+		t.Fatalf("Expected %d events, got %d", len(EventTypes()), len(events))
+	}
+
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			t.Errorf("%s: marshal failed: %v", ev.Type, err)
+			continue
+		}
+		var got Event
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("%s: unmarshal failed: %v", ev.Type, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, ev) {
+			t.Errorf("%s: expected %+v, got %+v", ev.Type, ev, got)
+		}
+	}
+}
+
+func TestEventJSONErrors(t *testing.T) {
+	// Legacy upper-case tags are rejected
+	var ev Event
+	if err := json.Unmarshal([]byte(`{"type":"TX_SIGNED","payload":{}}`), &ev); err == nil {
+		t.Error("Expected unknown type to fail")
+	}
+	if err := json.Unmarshal([]byte(`{"type":"log","payload":"text"}`), &ev); err == nil {
+		t.Error("Expected mismatched payload to fail")
+	}
+
+	// Type and payload must agree
+	if _, err := json.Marshal(Event{Type: EventError, Payload: LogPayload{Message: "x"}}); err == nil {
+		t.Error("Expected mismatched type to fail")
+	}
+	if _, err := json.Marshal(Event{Type: EventLog}); err == nil {
+		t.Error("Expected missing payload to fail")
+	}
+}
@@ -0,0 +1,212 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultSubscriberBuffer is the buffer size of a subscription when none is given.
+const DefaultSubscriberBuffer = 64
+
+// ErrSlowSubscriber is reported by Subscription.Err when a subscription with
+// the SlowDisconnect policy was dropped for falling behind.
+var ErrSlowSubscriber = errors.New("subscriber too slow, disconnected")
+
+// SlowPolicy decides what Publish does when a subscriber's buffer is full.
+type SlowPolicy int
+
+const (
+	// SlowDropOldest discards the oldest buffered event to make room.
+	SlowDropOldest SlowPolicy = iota
+	// SlowBlock makes the publisher wait until the subscriber catches up.
+	SlowBlock
+	// SlowDisconnect closes the subscription.
+	SlowDisconnect
+)
+
+// SubscribeOptions configures a subscription.
+type SubscribeOptions struct {
+	// Topics restricts the subscription to these event types; empty means all.
+	Topics []EventType
+	// Buffer is the number of events held for the subscriber; defaults to
+	// DefaultSubscriberBuffer.
+	Buffer int
+	// Policy applies when the buffer is full; defaults to SlowDropOldest.
+	Policy SlowPolicy
+}
+
+// Subscription receives the events published on a Hub for its topics.
+type Subscription struct {
+	hub    *Hub
+	topics map[EventType]bool
+	policy SlowPolicy
+	ch     chan Event
+
+	// done is closed first on unsubscribe so that a publisher blocked on
+	// a full buffer lets go of mu before ch is closed.
+	done     chan struct{}
+	doneOnce sync.Once
+
+	mu      sync.Mutex
+	closed  bool
+	err     error
+	dropped int
+}
+
+// Subscribe registers a new subscriber. Events published afterwards are
+// delivered on the subscription's channel until Unsubscribe is called.
+func (h *Hub) Subscribe(opts SubscribeOptions) *Subscription {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultSubscriberBuffer
+	}
+	s := &Subscription{
+		hub:    h,
+		policy: opts.Policy,
+		ch:     make(chan Event, opts.Buffer),
+		done:   make(chan struct{}),
+	}
+	if len(opts.Topics) > 0 {
+		s.topics = make(map[EventType]bool, len(opts.Topics))
+		for _, t := range opts.Topics {
+			s.topics[t] = true
+		}
+	}
+
+	h.subMu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[*Subscription]struct{})
+	}
+	h.subs[s] = struct{}{}
+	h.subMu.Unlock()
+	return s
+}
+
+// Publish delivers the event to every subscriber of its type, applying each
+// subscriber's slow policy.
+func (h *Hub) Publish(ev Event) {
+	h.subMu.RLock()
+	subs := make([]*Subscription, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.subMu.RUnlock()
+
+	for _, s := range subs {
+		if s.topics == nil || s.topics[ev.Type] {
+			s.deliver(ev)
+		}
+	}
+}
+
+// Start forwards events written to Outbound to the subscribers until ctx
+// is done, then closes all subscriptions. Outbound must not be read by
+// anyone else once Start has been called.
+func (h *Hub) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				h.closeSubscriptions()
+				return
+			case ev := <-h.Outbound:
+				h.RecordEvent(RecordOutbound, ev)
+				h.Publish(ev)
+			}
+		}
+	}()
+}
+
+func (h *Hub) closeSubscriptions() {
+	h.subMu.RLock()
+	subs := make([]*Subscription, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.subMu.RUnlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+func (h *Hub) removeSubscription(s *Subscription) {
+	h.subMu.Lock()
+	delete(h.subs, s)
+	h.subMu.Unlock()
+}
+
+// C returns the channel events are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Unsubscribe stops delivery and closes the channel. It is safe to call
+// more than once.
+func (s *Subscription) Unsubscribe() {
+	s.doneOnce.Do(func() { close(s.done) })
+	s.mu.Lock()
+	s.closeLocked(nil)
+	s.mu.Unlock()
+	s.hub.removeSubscription(s)
+}
+
+// Err returns ErrSlowSubscriber if the subscription was disconnected for
+// falling behind, nil otherwise.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Dropped returns the number of events discarded by the SlowDropOldest policy.
+func (s *Subscription) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *Subscription) deliver(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+
+	switch s.policy {
+	case SlowBlock:
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+	case SlowDisconnect:
+		s.doneOnce.Do(func() { close(s.done) })
+		s.closeLocked(ErrSlowSubscriber)
+		s.hub.removeSubscription(s)
+	default:
+		// Only publishers send on ch and they hold mu, so after taking one
+		// event out there is room for this one.
+		select {
+		case <-s.ch:
+			s.dropped++
+		default:
+		}
+		s.ch <- ev
+	}
+}
+
+func (s *Subscription) closeLocked(err error) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.ch)
+}
@@ -0,0 +1,118 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishTopics(t *testing.T) {
+	h := NewHub()
+	all := h.Subscribe(SubscribeOptions{})
+	errs := h.Subscribe(SubscribeOptions{Topics: []EventType{EventError}})
+
+	h.Publish(LogEvent("hello"))
+	h.Publish(ErrorEvent("boom"))
+
+	if len(all.C()) != 2 {
+		t.Errorf("Expected 2 events for the wildcard subscriber, got %d", len(all.C()))
+	}
+	if ev := <-errs.C(); ev.Type != EventError || len(errs.C()) != 0 {
+		t.Errorf("Expected only the error event, got %s and %d more", ev.Type, len(errs.C()))
+	}
+
+	// Unsubscribed subscribers get nothing and their channel is closed
+	errs.Unsubscribe()
+	errs.Unsubscribe()
+	h.Publish(ErrorEvent("again"))
+	if _, ok := <-errs.C(); ok {
+		t.Error("Expected closed channel after Unsubscribe")
+	}
+}
+
+func TestSlowDropOldest(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(SubscribeOptions{Buffer: 2})
+
+	for _, msg := range []string{"1", "2", "3"} {
+		h.Publish(LogEvent("%s", msg))
+	}
+
+	if got := (<-s.C()).Payload.(LogPayload).Message; got != "2" {
+		t.Errorf("Expected oldest event to be dropped, got '%s' first", got)
+	}
+	if s.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", s.Dropped())
+	}
+}
+
+func TestSlowDisconnect(t *testing.T) {
+	h := NewHub()
+	slow := h.Subscribe(SubscribeOptions{Buffer: 1, Policy: SlowDisconnect})
+	other := h.Subscribe(SubscribeOptions{})
+
+	h.Publish(LogEvent("1"))
+	h.Publish(LogEvent("2"))
+
+	<-slow.C()
+	if _, ok := <-slow.C(); ok {
+		t.Error("Expected slow subscriber to be disconnected")
+	}
+	if slow.Err() != ErrSlowSubscriber {
+		t.Errorf("Expected ErrSlowSubscriber, got %v", slow.Err())
+	}
+	if len(other.C()) != 2 {
+		t.Errorf("Expected other subscriber to get both events, got %d", len(other.C()))
+	}
+}
+
+func TestSlowBlock(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(SubscribeOptions{Buffer: 1, Policy: SlowBlock})
+	h.Publish(LogEvent("1"))
+
+	published := make(chan struct{})
+	go func() {
+		h.Publish(LogEvent("2"))
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("Expected Publish to block on a full subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	<-s.C()
+	<-published
+	if ev := <-s.C(); ev.Payload.(LogPayload).Message != "2" {
+		t.Errorf("Expected '2', got %v", ev.Payload)
+	}
+
+	// Unsubscribing releases a blocked publisher
+	h.Publish(LogEvent("3"))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Unsubscribe()
+	}()
+	h.Publish(LogEvent("4"))
+}
+
+func TestStartForwardsOutbound(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(SubscribeOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.Start(ctx)
+
+	h.Outbound <- LogEvent("hello")
+	if ev := <-s.C(); ev.Payload.(LogPayload).Message != "hello" {
+		t.Errorf("Expected 'hello', got %v", ev.Payload)
+	}
+
+	// Subscriptions are closed on shutdown
+	cancel()
+	if _, ok := <-s.C(); ok {
+		t.Error("Expected closed channel after shutdown")
+	}
+}
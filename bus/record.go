@@ -0,0 +1,85 @@
+package bus
+
+import "time"
+
+// RecordKind tells which Hub channel a recorded message travelled on.
+type RecordKind string
+
+const (
+	RecordInbound      RecordKind = "inbound"
+	RecordOutbound     RecordKind = "outbound"
+	RecordAction       RecordKind = "action"
+	RecordActionResult RecordKind = "action_result"
+	RecordSignRequest  RecordKind = "sign_request"
+	RecordSignResponse RecordKind = "sign_response"
+)
+
+// Record is a message observed on the Hub. Exactly one of the message
+// fields is set, depending on Kind.
+type Record struct {
+	Time         time.Time     `json:"time"`
+	Kind         RecordKind    `json:"kind"`
+	Event        *Event        `json:"event,omitempty"`
+	Action       *Action       `json:"action,omitempty"`
+	ActionResult *ActionResult `json:"action_result,omitempty"`
+	SignRequest  *SignRequest  `json:"sign_request,omitempty"`
+	SignResponse *SignResponse `json:"sign_response,omitempty"`
+	// Error holds the error of an ActionResult or SignResponse, which does
+	// not survive JSON encoding itself.
+	Error string `json:"error,omitempty"`
+}
+
+// SetRecorder installs fn to observe every message passing through the
+// Hub; nil removes it. fn must not block.
+func (h *Hub) SetRecorder(fn func(Record)) {
+	h.recMu.Lock()
+	h.recorder = fn
+	h.recMu.Unlock()
+}
+
+// Record hands a message to the recorder, if one is installed. The
+// receiving end of each channel calls it, so that nothing is recorded twice.
+func (h *Hub) Record(r Record) {
+	h.recMu.RLock()
+	fn := h.recorder
+	h.recMu.RUnlock()
+	if fn == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	fn(r)
+}
+
+// RecordEvent records an inbound or outbound event.
+func (h *Hub) RecordEvent(kind RecordKind, ev Event) {
+	h.Record(Record{Kind: kind, Event: &ev})
+}
+
+// RecordAction records an action received by the bridge.
+func (h *Hub) RecordAction(a Action) {
+	h.Record(Record{Kind: RecordAction, Action: &a})
+}
+
+// RecordActionResult records the outcome of an action.
+func (h *Hub) RecordActionResult(r ActionResult) {
+	h.Record(Record{Kind: RecordActionResult, ActionResult: &r, Error: errorString(r.Error)})
+}
+
+// RecordSignRequest records a sign request received by the vault.
+func (h *Hub) RecordSignRequest(r SignRequest) {
+	h.Record(Record{Kind: RecordSignRequest, SignRequest: &r})
+}
+
+// RecordSignResponse records the vault's answer to a sign request.
+func (h *Hub) RecordSignResponse(r SignResponse) {
+	h.Record(Record{Kind: RecordSignResponse, SignResponse: &r, Error: errorString(r.Error)})
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lucci-labs/luccibot/agent"
+	"github.com/lucci-labs/luccibot/bridge"
+	"github.com/lucci-labs/luccibot/bus"
+	"github.com/lucci-labs/luccibot/journal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replaySkillsDir string
+	replayTimeout   time.Duration
+)
+
+// journalCmd groups the commands working on the event journal.
+var journalCmd = &cobra.Command{
+	Use:   "journal",
+	Short: "Inspect and replay the event journal",
+	Long: `Every message passing through the hub is recorded as JSON lines in
+~/.luccibot/journal, one session per run.`,
+}
+
+var journalListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded sessions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := journal.DefaultDir()
+		if err != nil {
+			return err
+		}
+		sessions, err := journal.Sessions(dir)
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			fmt.Println(s)
+		}
+		return nil
+	},
+}
+
+var journalReplayCmd = &cobra.Command{
+	Use:   "replay [session]",
+	Short: "Feed a recorded session back through a headless agent and bridge",
+	Long: `Replay sends the user messages and commands of a recorded session (the
+latest one by default) to a fresh agent and bridge, one turn at a time, and
+prints every resulting event as a JSON line. Signing is refused during replay.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := journal.DefaultDir()
+		if err != nil {
+			return err
+		}
+		session := ""
+		if len(args) > 0 {
+			session = args[0]
+		} else {
+			sessions, err := journal.Sessions(dir)
+			if err != nil {
+				return err
+			}
+			if len(sessions) == 0 {
+				return errors.New("no recorded sessions")
+			}
+			session = sessions[len(sessions)-1]
+		}
+		records, err := journal.ReadSession(dir, session)
+		if err != nil {
+			return err
+		}
+		return replay(cmd.Context(), records)
+	},
+}
+
+// replay runs the recorded inputs through a headless agent and bridge.
+func replay(ctx context.Context, records []bus.Record) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	provider, err := agent.NewProvider(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("LLM provider unavailable: %w", err)
+	}
+
+	h := bus.NewHub()
+	b := bridge.NewBridge(h, replaySkillsDir)
+	b.Timeout = time.Duration(cfg.SkillTimeout) * time.Second
+	b.MaxConcurrent = cfg.SkillConcurrency
+	b.Isolate = cfg.SkillIsolation
+	b.SkillConfig = cfg.SkillConfig
+	b.RequireApproval = cfg.SkillApproval
+	lock, err := openLockfile()
+	if err != nil {
+		return err
+	}
+	b.Lock = lock
+	if err := b.LoadSkills(); err != nil {
+		return err
+	}
+	b.Start(ctx)
+	defer func() {
+		// Shut the skill daemons down before returning.
+		cancel()
+		b.Wait()
+	}()
+
+	a := agent.NewAgent(h, provider)
+	addSkills(a, b.Registry())
+	a.TokenBudget = cfg.SessionTokenBudget
+	a.Prices = cfg.PriceTable()
+	go a.Start(ctx)
+
+	// Refuse to sign anything while replaying
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case req := <-h.SignReq:
+				req.ResponseChan <- bus.SignResponse{
+					RequestID: req.RequestID,
+					Error:     errors.New("signing is disabled during replay"),
+				}
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(os.Stdout)
+	return journal.Replay(ctx, h, records, replayTimeout, func(ev bus.Event) {
+		if err := enc.Encode(ev); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(journalCmd)
+	journalCmd.AddCommand(journalListCmd)
+	journalCmd.AddCommand(journalReplayCmd)
+
+	journalReplayCmd.Flags().StringVar(&replaySkillsDir, "skills", "./skills", "directory of the skills run by the bridge")
+	journalReplayCmd.Flags().DurationVar(&replayTimeout, "timeout", 2*time.Minute, "maximum duration of a single turn")
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lucci-labs/luccibot/vault"
+	"github.com/spf13/cobra"
+)
+
+var keystoreDir string
+
+// keysCmd groups the commands managing the signing keys of the vault.
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the keys the vault signs with",
+	Long: `Keys are stored in ~/.luccibot/keystore as Web3 Secret Storage (v3) files,
+encrypted under a passphrase, so they can be moved to and from geth or foundry
+keystores. The vault signs with the account set as vault_account in the config,
+or the oldest one.`,
+}
+
+var keysListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the accounts of the keystore",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore()
+		if err != nil {
+			return err
+		}
+		accounts, err := ks.Accounts()
+		if err != nil {
+			return err
+		}
+		for _, a := range accounts {
+			fmt.Printf("%s %s\n", a.Address, a.Path)
+		}
+		return nil
+	},
+}
+
+var keysNewCmd = &cobra.Command{
+	Use:          "new",
+	Short:        "Generate a key",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore()
+		if err != nil {
+			return err
+		}
+		passphrase, err := newPassphrase("Passphrase of the key: ")
+		if err != nil {
+			return err
+		}
+		account, err := ks.NewAccount(passphrase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s\n", account.Address)
+		return nil
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:          "import <file>",
+	Short:        "Import a key file or a hex private key",
+	Long:         "Imports a v3 key file, e.g. from geth or foundry, or a file holding a private key as hex.",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		defer clear(data)
+
+		var account vault.Account
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+			passphrase, err := readSecret("Passphrase of the key file: ")
+			if err != nil {
+				return err
+			}
+			key, err := vault.DecryptKey(data, passphrase)
+			if err != nil {
+				return err
+			}
+			defer clear(key)
+			newPass, err := newPassphrase("New passphrase: ")
+			if err != nil {
+				return err
+			}
+			if account, err = ks.ImportKey(key, newPass); err != nil {
+				return err
+			}
+		} else {
+			key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+			if err != nil {
+				return errors.New("file is neither a key file nor a hex private key")
+			}
+			defer clear(key)
+			passphrase, err := newPassphrase("Passphrase of the key: ")
+			if err != nil {
+				return err
+			}
+			if account, err = ks.ImportKey(key, passphrase); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Imported %s\n", account.Address)
+		return nil
+	},
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:          "export <address>",
+	Short:        "Print the key file of an account, under a new passphrase",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore()
+		if err != nil {
+			return err
+		}
+		passphrase, err := readSecret("Passphrase of the key: ")
+		if err != nil {
+			return err
+		}
+		newPass, err := newPassphrase("Passphrase of the exported file: ")
+		if err != nil {
+			return err
+		}
+		data, err := ks.Export(args[0], passphrase, newPass)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+var keysPasswdCmd = &cobra.Command{
+	Use:          "passwd <address>",
+	Short:        "Change the passphrase of an account",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore()
+		if err != nil {
+			return err
+		}
+		passphrase, err := readSecret("Current passphrase: ")
+		if err != nil {
+			return err
+		}
+		newPass, err := newPassphrase("New passphrase: ")
+		if err != nil {
+			return err
+		}
+		return ks.ChangePassword(args[0], passphrase, newPass)
+	},
+}
+
+// newPassphrase reads a new passphrase twice.
+func newPassphrase(prompt string) (string, error) {
+	passphrase, err := readSecret(prompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	again, err := readSecret("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysNewCmd)
+	keysCmd.AddCommand(keysImportCmd)
+	keysCmd.AddCommand(keysExportCmd)
+	keysCmd.AddCommand(keysPasswdCmd)
+
+	keysCmd.PersistentFlags().StringVar(&keystoreDir, "keystore", "", "keystore directory (default ~/.luccibot/keystore)")
+}
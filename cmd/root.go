@@ -8,11 +8,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/lucci-labs/luccibot/agent"
 	"github.com/lucci-labs/luccibot/bridge"
 	"github.com/lucci-labs/luccibot/bus"
+	"github.com/lucci-labs/luccibot/config"
+	"github.com/lucci-labs/luccibot/journal"
 	"github.com/lucci-labs/luccibot/tui"
 	"github.com/lucci-labs/luccibot/vault"
 	"github.com/spf13/cobra"
@@ -34,39 +37,96 @@ to help you manage tasks and transactions directly from your terminal.`,
 		// 1. Initialize the Hub (Bus)
 		h := bus.NewHub()
 
+		// Load configuration; environment variables override the config file.
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Journal every Hub message to ~/.luccibot/journal.
+		if j, err := openJournal(); err != nil {
+			h.Outbound <- bus.ErrorEvent("Journal unavailable: %v", err)
+		} else {
+			h.SetRecorder(j.Record)
+			defer j.Close()
+		}
+
 		// 2. Initialize Services
-		// Vault (Passive)
-		v := vault.NewLocalVault("default-key-id")
+		// Vault (Passive); it starts locked until unlocked from the TUI
+		v := vault.NewLocalVault(cfg.VaultAccount)
+		if cfg.VaultIdleLock != 0 {
+			v.IdleTimeout = time.Duration(cfg.VaultIdleLock) * time.Second
+		}
+		approvalTimeout := vault.DefaultApprovalTimeout
+		if cfg.SignApprovalTimeout > 0 {
+			approvalTimeout = time.Duration(cfg.SignApprovalTimeout) * time.Second
+		}
 
 		// Bridge (Skills execution)
 		// Assuming "skills" directory is in the current working directory
 		b := bridge.NewBridge(h, "./skills")
+		b.Timeout = time.Duration(cfg.SkillTimeout) * time.Second
+		b.MaxConcurrent = cfg.SkillConcurrency
+		b.Isolate = cfg.SkillIsolation
+		b.SkillConfig = cfg.SkillConfig
+		b.RequireApproval = cfg.SkillApproval
+		if lock, err := openLockfile(); err != nil {
+			// Without the pins no skill can be trusted.
+			h.Outbound <- bus.ErrorEvent("Skill lockfile unavailable, refusing all skills: %v", err)
+			b.Lock = &bridge.Lockfile{}
+			b.RequireApproval = true
+		} else {
+			b.Lock = lock
+		}
+		// The secret store is unlocked along with the vault.
+		secrets, err := openSecretStore()
+		if err != nil {
+			h.Outbound <- bus.ErrorEvent("Secret store unavailable: %v", err)
+		} else {
+			b.Secrets = secrets
+		}
+		if err := b.LoadSkills(); err != nil {
+			h.Outbound <- bus.ErrorEvent("Some skills were not loaded: %v", err)
+		}
 		b.Start(ctx) // This runs in its own goroutine internally
 
 		// Agent (Brain)
-		a := agent.NewAgent(h)
+		provider, err := agent.NewProvider(ctx, cfg)
+		if err != nil {
+			h.Outbound <- bus.ErrorEvent("LLM provider unavailable: %v", err)
+		}
+		a := agent.NewAgent(h, provider)
+		addSkills(a, b.Registry())
+		a.TokenBudget = cfg.SessionTokenBudget
+		a.Prices = cfg.PriceTable()
+		if ledger, err := openUsageLedger(); err != nil {
+			h.Outbound <- bus.ErrorEvent("Usage ledger unavailable: %v", err)
+		} else {
+			a.Ledger = ledger
+		}
+		if store, err := openSessionStore(); err != nil {
+			h.Outbound <- bus.ErrorEvent("Session storage unavailable: %v", err)
+		} else {
+			a.Sessions = store
+		}
 
 		// TUI (Face)
 		tuiModel := tui.NewModel(h)
+		if provider != nil {
+			tuiModel = tuiModel.WithModelInfo(provider.Model(), provider.Name())
+		}
 		p := tea.NewProgram(tuiModel)
 
+		// Fan Outbound events out to subscribers, now that the TUI has subscribed.
+		h.Start(ctx)
+
 		// 3. Orchestration with errgroup
 		g, ctx := errgroup.WithContext(ctx)
 
 		// Start Vault Loop (Adapter)
 		g.Go(func() error {
-			for {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case req := <-h.SignReq:
-					sig, err := v.SignTransaction(req.TxData)
-					req.ResponseChan <- bus.SignResponse{
-						Signature: sig,
-						Error:     err,
-					}
-				}
-			}
+			return serveVault(ctx, h, v, secrets, approvalTimeout)
 		})
 
 		// Start Agent
@@ -85,8 +145,12 @@ to help you manage tasks and transactions directly from your terminal.`,
 			return err
 		})
 
-		// Wait for all services
-		if err := g.Wait(); err != nil {
+		// Wait for all services, then give the skill daemons time to shut
+		// down gracefully.
+		err = g.Wait()
+		cancel()
+		b.Wait()
+		if err != nil {
 			// Context canceled is expected exit
 			if err != context.Canceled {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -96,6 +160,94 @@ to help you manage tasks and transactions directly from your terminal.`,
 	},
 }
 
+// addSkills declares the installed skills as LLM tools and gives each to the
+// specialist its manifest names, since the manager only reaches skills
+// through a specialist.
+func addSkills(a *agent.Agent, r *bridge.Registry) {
+	var tools []agent.Tool
+	for _, m := range r.List() {
+		t := agent.Tool{
+			Name:        m.Name,
+			Description: m.Description,
+			Parameters:  m.Parameters,
+		}
+		tools = append(tools, t)
+		a.Specialists.AddTool(m.Specialist, t)
+	}
+	a.Tools = agent.MergeTools(a.Tools, tools)
+}
+
+// loadConfig reads the config file at the default path and applies environment overrides.
+func loadConfig() (*config.Config, error) {
+	path, err := config.DefaultConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.NewConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.LoadFromEnv()
+	return cfg, nil
+}
+
+// openSessionStore opens the conversation store under ~/.luccibot/sessions.
+func openSessionStore() (*agent.SessionStore, error) {
+	dir, err := agent.DefaultSessionDir()
+	if err != nil {
+		return nil, err
+	}
+	return agent.NewSessionStore(dir)
+}
+
+// openJournal starts a new journal session under ~/.luccibot/journal.
+func openJournal() (*journal.Journal, error) {
+	dir, err := journal.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return journal.Open(dir)
+}
+
+// openUsageLedger opens the per-day usage totals at ~/.luccibot/usage.json.
+func openUsageLedger() (*agent.UsageLedger, error) {
+	path, err := agent.DefaultUsagePath()
+	if err != nil {
+		return nil, err
+	}
+	return agent.OpenUsageLedger(path)
+}
+
+// openLockfile opens the skill pins at ~/.luccibot/skills.lock.
+func openLockfile() (*bridge.Lockfile, error) {
+	path, err := bridge.DefaultLockPath()
+	if err != nil {
+		return nil, err
+	}
+	return bridge.OpenLockfile(path)
+}
+
+// openSecretStore opens the locked secret store at ~/.luccibot/secrets.json.
+func openSecretStore() (*vault.SecretStore, error) {
+	path, err := vault.DefaultSecretsPath()
+	if err != nil {
+		return nil, err
+	}
+	return vault.OpenSecretStore(path)
+}
+
+// openKeystore opens the key files in ~/.luccibot/keystore.
+func openKeystore() (*vault.Keystore, error) {
+	dir := keystoreDir
+	if dir == "" {
+		var err error
+		if dir, err = vault.DefaultKeystoreDir(); err != nil {
+			return nil, err
+		}
+	}
+	return vault.NewKeystore(dir), nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lucci-labs/luccibot/bridge"
+	"github.com/lucci-labs/luccibot/vault"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// secretsCmd groups the commands managing the secrets of skills.
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets the vault hands to skills",
+	Long: `Secrets such as exchange API keys are stored encrypted in
+~/.luccibot/secrets.json under a passphrase. A skill receives the secrets its
+manifest lists once they are approved for it, and every hand-out is logged to
+~/.luccibot/secrets-audit.jsonl. The passphrase is read from ` + vault.PassphraseEnv + `
+or prompted for; use the vault's, since LucciBot unlocks the store with it.`,
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List stored secrets",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		for _, name := range store.Names() {
+			fmt.Println(name)
+		}
+		return nil
+	},
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:          "set <name>",
+	Short:        "Store a secret, read from the terminal or stdin",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := unlockSecretStore()
+		if err != nil {
+			return err
+		}
+		value, err := readSecret(fmt.Sprintf("Value of %s: ", args[0]))
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return errors.New("empty secret")
+		}
+		return store.Set(args[0], value)
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:          "delete <name>",
+	Short:        "Delete a secret",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		return store.Delete(args[0])
+	},
+}
+
+var secretsApproveCmd = &cobra.Command{
+	Use:          "approve <skill>",
+	Short:        "Allow a skill to receive the secrets its manifest lists",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, lock, err := loadSkills()
+		if err != nil {
+			return err
+		}
+		m, ok := registry.Get(args[0])
+		if !ok {
+			return fmt.Errorf("skill %s is not installed", args[0])
+		}
+		if len(m.Secrets) == 0 {
+			return fmt.Errorf("skill %s lists no secrets", m.Name)
+		}
+		// The grant holds for the skill's files as pinned, so they must
+		// match.
+		pin, ok := lock.Pinned(m.Name)
+		if !ok {
+			return fmt.Errorf("skill %s is not approved; run `luccibot skills approve %s` first", m.Name, m.Name)
+		}
+		sum, err := bridge.HashSkill(m)
+		if err != nil {
+			return err
+		}
+		if sum != pin.SHA256 {
+			return fmt.Errorf("skill %s has changed since it was approved; run `luccibot skills approve %s` first", m.Name, m.Name)
+		}
+		store, err := unlockSecretStore()
+		if err != nil {
+			return err
+		}
+		if err := store.Grant(m.Name, pin.SHA256, m.Secrets); err != nil {
+			return err
+		}
+		fmt.Printf("Skill %s may now receive %s\n", m.Name, strings.Join(m.Secrets, ", "))
+		stored := store.Names()
+		for _, name := range m.Secrets {
+			if !slices.Contains(stored, name) {
+				fmt.Printf("Secret %s is not set yet; run `luccibot secrets set %s`\n", name, name)
+			}
+		}
+		return nil
+	},
+}
+
+var secretsRevokeCmd = &cobra.Command{
+	Use:          "revoke <skill>",
+	Short:        "Withdraw every secret approved for a skill",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := unlockSecretStore()
+		if err != nil {
+			return err
+		}
+		return store.Grant(args[0], "", nil)
+	},
+}
+
+// unlockSecretStore opens the secret store and unlocks it with the
+// passphrase from the environment or the terminal. A new store asks for the
+// passphrase twice.
+func unlockSecretStore() (*vault.SecretStore, error) {
+	store, err := openSecretStore()
+	if err != nil {
+		return nil, err
+	}
+	passphrase := os.Getenv(vault.PassphraseEnv)
+	if passphrase == "" {
+		if passphrase, err = readSecret("Vault passphrase: "); err != nil {
+			return nil, err
+		}
+		if !store.Initialized() {
+			again, err := readSecret("Repeat passphrase: ")
+			if err != nil {
+				return nil, err
+			}
+			if again != passphrase {
+				return nil, errors.New("passphrases do not match")
+			}
+		}
+	}
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	if err := store.Unlock(passphrase); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// stdin is shared by the prompts, so that buffered input is not lost
+// between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readSecret reads a line without echo from the terminal, or from stdin
+// when it is not a terminal.
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(data), err
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsDeleteCmd)
+	secretsCmd.AddCommand(secretsApproveCmd)
+	secretsCmd.AddCommand(secretsRevokeCmd)
+
+	secretsApproveCmd.Flags().StringVar(&skillsDir, "skills", "./skills", "directory of the installed skills")
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lucci-labs/luccibot/bridge"
+	"github.com/spf13/cobra"
+)
+
+var (
+	skillsDir  string
+	approveAll bool
+)
+
+// skillsCmd groups the commands managing installed skills.
+var skillsCmd = &cobra.Command{
+	Use:   "skills",
+	Short: "List and approve installed skills",
+	Long: `Approved skills are pinned to the SHA-256 hash of their files in
+~/.luccibot/skills.lock. A pinned skill whose files change is refused until it
+is approved again.`,
+}
+
+var skillsListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List installed skills and their approval",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, lock, err := loadSkills()
+		if err != nil {
+			return err
+		}
+		for _, m := range registry.List() {
+			fmt.Printf("%-24s %-8s %s\n", m.Name, m.RuntimeName(), approval(lock, m))
+		}
+		return nil
+	},
+}
+
+var skillsApproveCmd = &cobra.Command{
+	Use:          "approve [skill...]",
+	Short:        "Pin skills to the current hash of their files",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, lock, err := loadSkills()
+		if err != nil {
+			return err
+		}
+		var skills []bridge.Manifest
+		switch {
+		case approveAll:
+			skills = registry.List()
+		case len(args) == 0:
+			return errors.New("name the skills to approve or pass --all")
+		}
+		for _, name := range args {
+			m, ok := registry.Get(name)
+			if !ok {
+				return fmt.Errorf("skill %s is not installed", name)
+			}
+			skills = append(skills, m)
+		}
+		for _, m := range skills {
+			pin, err := lock.Approve(m)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Approved %s (sha256 %s)\n", m.Name, pin.SHA256)
+		}
+		return nil
+	},
+}
+
+// loadSkills scans the skills directory and opens the lockfile.
+func loadSkills() (*bridge.Registry, *bridge.Lockfile, error) {
+	registry := bridge.NewRegistry()
+	if err := registry.Scan(skillsDir); err != nil {
+		return nil, nil, err
+	}
+	lock, err := openLockfile()
+	if err != nil {
+		return nil, nil, err
+	}
+	return registry, lock, nil
+}
+
+// approval describes whether the skill matches its pin.
+func approval(lock *bridge.Lockfile, m bridge.Manifest) string {
+	pin, ok := lock.Pinned(m.Name)
+	if !ok {
+		return "not approved"
+	}
+	sum, err := bridge.HashSkill(m)
+	if err != nil {
+		return err.Error()
+	}
+	if sum != pin.SHA256 {
+		return "changed since approval"
+	}
+	return "approved " + pin.ApprovedAt.Local().Format("2006-01-02 15:04")
+}
+
+func init() {
+	rootCmd.AddCommand(skillsCmd)
+	skillsCmd.AddCommand(skillsListCmd)
+	skillsCmd.AddCommand(skillsApproveCmd)
+
+	skillsCmd.PersistentFlags().StringVar(&skillsDir, "skills", "./skills", "directory of the installed skills")
+	skillsApproveCmd.Flags().BoolVar(&approveAll, "all", false, "approve every installed skill")
+}
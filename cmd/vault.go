@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+	"github.com/lucci-labs/luccibot/vault"
+)
+
+// vaultIdleCheck is how often serveVault checks for the idle timeout.
+const vaultIdleCheck = time.Second
+
+// pendingSign is a sign request waiting for the user's decision.
+type pendingSign struct {
+	req     bus.SignRequest
+	expires time.Time
+}
+
+// serveVault is the adapter loop of the vault. It answers sign requests,
+// which fail with vault.ErrLocked until the user unlocks the vault from the
+// TUI, and locks it when asked to or once idle. Every transaction is shown
+// to the user as a "sign_approval" event and signed only once approved on
+// Hub.SignDecisions; without a decision within approvalTimeout it is
+// rejected. The passphrase that unlocks the vault also unlocks secrets, if
+// not nil, and every lock locks it too. The vault is locked when the loop
+// ends.
+func serveVault(ctx context.Context, h *bus.Hub, v *vault.LocalVault, secrets *vault.SecretStore, approvalTimeout time.Duration) error {
+	lockSecrets := func() {
+		if secrets != nil {
+			secrets.Lock()
+		}
+	}
+	defer lockSecrets()
+	defer v.Lock()
+	pending := make(map[string]pendingSign)
+	// finish answers a pending request and reports the decision.
+	finish := func(p pendingSign, resp bus.SignResponse, decision bus.SignDecisionPayload) {
+		delete(pending, p.req.RequestID)
+		h.Outbound <- bus.NewEvent(decision).WithParent(p.req.RequestID)
+		p.req.ResponseChan <- resp
+	}
+
+	status := func(reason bus.VaultReason, parentID string) {
+		p := bus.VaultStatusPayload{Locked: v.Locked(), Reason: reason}
+		if !p.Locked {
+			p.Account, _ = v.Address()
+		}
+		h.Outbound <- bus.NewEvent(p).WithParent(parentID)
+	}
+	status(bus.VaultStartup, "")
+
+	ticker := time.NewTicker(vaultIdleCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case now := <-ticker.C:
+			for id, p := range pending {
+				if now.After(p.expires) {
+					finish(p, bus.SignResponse{
+						RequestID: id,
+						Error:     fmt.Errorf("%w: no decision within %s", vault.ErrRejected, approvalTimeout),
+					}, bus.SignDecisionPayload{SignRequestID: id, TimedOut: true})
+				}
+			}
+			// The user is still reading a transaction; keep the key until
+			// it is decided.
+			if len(pending) == 0 && v.LockIfIdle(now) {
+				lockSecrets()
+				status(bus.VaultIdle, "")
+			}
+
+		case c := <-h.VaultCtl:
+			var err error
+			switch c.Action {
+			case bus.VaultUnlock:
+				if err = unlockVault(v, c.Passphrase); err == nil {
+					status("", "")
+					unlockSecrets(h, secrets, c.Passphrase)
+				}
+			case bus.VaultLock:
+				lockSecrets()
+				if !v.Locked() {
+					v.Lock()
+					status(c.Reason, "")
+				}
+			default:
+				err = errors.New("unknown vault action " + string(c.Action))
+			}
+			if c.ResponseChan != nil {
+				c.ResponseChan <- err
+			}
+
+		case req := <-h.SignReq:
+			h.RecordSignRequest(req)
+			from, err := v.Address()
+			if err != nil {
+				// The TUI answers with an unlock prompt.
+				status(bus.VaultSignRejected, req.RequestID)
+				req.ResponseChan <- bus.SignResponse{RequestID: req.RequestID, Error: err}
+				continue
+			}
+			tx, err := vault.ParseTxRequest(req.TxData)
+			if err != nil {
+				req.ResponseChan <- bus.SignResponse{RequestID: req.RequestID, Error: err}
+				continue
+			}
+			p := pendingSign{req: req, expires: time.Now().Add(approvalTimeout)}
+			pending[req.RequestID] = p
+			s := tx.Summary()
+			h.Outbound <- bus.NewEvent(bus.SignApprovalPayload{
+				SignRequestID: req.RequestID,
+				From:          from,
+				Chain:         s.Chain,
+				ChainID:       s.ChainID,
+				To:            s.To,
+				Amount:        s.Amount,
+				Token:         s.Token,
+				GasCost:       s.GasCost,
+				GasToken:      s.Native,
+				Calldata:      s.Calldata,
+				Expires:       p.expires,
+			}).WithParent(req.RequestID)
+
+		case d := <-h.SignDecisions:
+			p, ok := pending[d.SignRequestID]
+			if !ok {
+				// Already timed out.
+				continue
+			}
+			resp := bus.SignResponse{RequestID: d.SignRequestID, Error: vault.ErrRejected}
+			if d.Approved {
+				resp.Error = nil
+				if signed, err := v.SignTransaction(p.req.TxData); err != nil {
+					resp.Error = err
+				} else {
+					resp.Signature, resp.TxHash = []byte(signed.RawHex()), signed.HashHex()
+				}
+			}
+			finish(p, resp, bus.SignDecisionPayload{SignRequestID: d.SignRequestID, Approved: d.Approved})
+			if errors.Is(resp.Error, vault.ErrLocked) {
+				// Locked while the user was deciding.
+				status(bus.VaultSignRejected, d.SignRequestID)
+			}
+		}
+	}
+}
+
+// unlockVault loads the vault's key from the keystore or, for accounts not
+// in it, from the HD wallet.
+func unlockVault(v *vault.LocalVault, passphrase string) error {
+	ks, err := openKeystore()
+	if err != nil {
+		return err
+	}
+	err = v.Unlock(ks, passphrase)
+	if !errors.Is(err, vault.ErrNoAccount) {
+		return err
+	}
+	w, err := openHDWallet()
+	if err != nil {
+		return err
+	}
+	return v.Unlock(w, passphrase)
+}
+
+// unlockSecrets unlocks the secret store with the vault's passphrase. A
+// store without a passphrase yet is left to `luccibot secrets set`.
+func unlockSecrets(h *bus.Hub, secrets *vault.SecretStore, passphrase string) {
+	if secrets == nil || !secrets.Initialized() || !secrets.Locked() {
+		return
+	}
+	if err := secrets.Unlock(passphrase); err != nil {
+		h.Outbound <- bus.ErrorEvent("Secret store stays locked: %v", err)
+	}
+}
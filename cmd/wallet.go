@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lucci-labs/luccibot/vault"
+	"github.com/spf13/cobra"
+)
+
+var (
+	walletPath  string
+	walletWords int
+	walletChain string
+)
+
+// walletCmd groups the commands managing the HD wallet.
+var walletCmd = &cobra.Command{
+	Use:   "wallet",
+	Short: "Derive accounts from a BIP-39 mnemonic",
+	Long: `The HD wallet keeps one BIP-39 seed in ~/.luccibot/wallet.json, encrypted under
+a passphrase like a keystore file, and derives accounts from it on the BIP-44
+paths of MetaMask (m/44'/60'/0'/0/n) and Phantom (m/44'/501'/n'/0'). The vault
+signs with the EVM account set as vault_account in the config when it is not in
+the keystore, or the first one if the keystore is empty.`,
+}
+
+var walletCreateCmd = &cobra.Command{
+	Use:          "create",
+	Short:        "Generate a mnemonic and store its seed",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if walletWords < 12 || walletWords > 24 || walletWords%3 != 0 {
+			return fmt.Errorf("invalid --words %d; use 12, 15, 18, 21 or 24", walletWords)
+		}
+		w, err := openHDWallet()
+		if err != nil {
+			return err
+		}
+		if w.Initialized() {
+			return errors.New("wallet already has a seed")
+		}
+		mnemonic, err := vault.NewMnemonic(walletWords / 3 * 32)
+		if err != nil {
+			return err
+		}
+		return storeMnemonic(w, mnemonic, true)
+	},
+}
+
+var walletImportCmd = &cobra.Command{
+	Use:          "import",
+	Short:        "Store the seed of an existing mnemonic",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := openHDWallet()
+		if err != nil {
+			return err
+		}
+		if w.Initialized() {
+			return errors.New("wallet already has a seed")
+		}
+		mnemonic, err := readSecret("Mnemonic: ")
+		if err != nil {
+			return err
+		}
+		mnemonic = strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+		if err := vault.ValidateMnemonic(mnemonic); err != nil {
+			return err
+		}
+		return storeMnemonic(w, mnemonic, false)
+	},
+}
+
+var walletDeriveCmd = &cobra.Command{
+	Use:          "derive",
+	Short:        "Derive the next account of a chain",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := openHDWallet()
+		if err != nil {
+			return err
+		}
+		passphrase, err := readSecret("Vault passphrase: ")
+		if err != nil {
+			return err
+		}
+		account, err := w.Derive(vault.Chain(walletChain), passphrase)
+		if err != nil {
+			return err
+		}
+		printHDAccount(account)
+		return nil
+	},
+}
+
+var walletListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the derived accounts",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := openHDWallet()
+		if err != nil {
+			return err
+		}
+		for _, a := range w.Accounts() {
+			printHDAccount(a)
+		}
+		return nil
+	},
+}
+
+// storeMnemonic asks for the optional BIP-39 passphrase and the vault
+// passphrase, and stores the seed of the mnemonic. A generated mnemonic is
+// shown once, for the user to write down.
+func storeMnemonic(w *vault.HDWallet, mnemonic string, show bool) error {
+	if show {
+		fmt.Fprintf(os.Stderr, "Write down this mnemonic; it is the only backup of your accounts:\n\n%s\n\n", mnemonic)
+	}
+	extra, err := readSecret("BIP-39 passphrase (optional): ")
+	if err != nil {
+		return err
+	}
+	passphrase, err := newPassphrase("Vault passphrase: ")
+	if err != nil {
+		return err
+	}
+	if err := w.Create(mnemonic, extra, passphrase); err != nil {
+		return err
+	}
+	for _, a := range w.Accounts() {
+		printHDAccount(a)
+	}
+	return nil
+}
+
+func printHDAccount(a vault.HDAccount) {
+	fmt.Printf("%-6s %-20s %s\n", a.Chain, a.Path, a.Address)
+}
+
+// openHDWallet opens the HD wallet at ~/.luccibot/wallet.json.
+func openHDWallet() (*vault.HDWallet, error) {
+	path := walletPath
+	if path == "" {
+		var err error
+		if path, err = vault.DefaultWalletPath(); err != nil {
+			return nil, err
+		}
+	}
+	return vault.OpenHDWallet(path)
+}
+
+func init() {
+	rootCmd.AddCommand(walletCmd)
+	walletCmd.AddCommand(walletCreateCmd)
+	walletCmd.AddCommand(walletImportCmd)
+	walletCmd.AddCommand(walletDeriveCmd)
+	walletCmd.AddCommand(walletListCmd)
+
+	walletCmd.PersistentFlags().StringVar(&walletPath, "wallet", "", "HD wallet file (default ~/.luccibot/wallet.json)")
+	walletCreateCmd.Flags().IntVar(&walletWords, "words", 24, "number of words of the mnemonic: 12, 15, 18, 21 or 24")
+	walletDeriveCmd.Flags().StringVar(&walletChain, "chain", string(vault.ChainEVM), "chain of the account: evm or solana")
+}
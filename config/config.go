@@ -9,7 +9,43 @@ import (
 
 type Config struct {
 	ProviderKeys map[string]string `json:"provider_keys"`
+	// ProviderURLs overrides the API endpoint per provider, e.g. a self-hosted
+	// OpenAI-compatible server for "openai".
+	ProviderURLs map[string]string `json:"provider_urls,omitempty"`
 	ActiveModel  string            `json:"active_model"`
+	// SessionTokenBudget is the conversation size, in tokens, above which old
+	// turns are summarized. Zero selects the agent default.
+	SessionTokenBudget int `json:"session_token_budget,omitempty"`
+	// SkillTimeout is the maximum run time of a skill in seconds, unless its
+	// manifest sets one. Zero selects the bridge default.
+	SkillTimeout int `json:"skill_timeout,omitempty"`
+	// SkillConcurrency is the number of skills run at once. Zero selects the
+	// bridge default.
+	SkillConcurrency int `json:"skill_concurrency,omitempty"`
+	// SkillIsolation runs skills in their own Linux namespaces, without
+	// network access unless their manifest asks for it, and out of reach of
+	// files outside their directory.
+	SkillIsolation bool `json:"skill_isolation,omitempty"`
+	// SkillApproval refuses skills that are not pinned in
+	// ~/.luccibot/skills.lock; pinned skills are always checked.
+	SkillApproval bool `json:"skill_approval,omitempty"`
+	// SkillConfig holds per-skill settings, keyed by skill name, that WASM
+	// skills with the config permission can read.
+	SkillConfig map[string]map[string]string `json:"skill_config,omitempty"`
+	// VaultAccount is the address of the keystore or HD wallet account the
+	// vault signs with. Empty selects the oldest keystore account, or the
+	// first EVM account of the HD wallet.
+	VaultAccount string `json:"vault_account,omitempty"`
+	// SignApprovalTimeout is the number of seconds the vault waits for the
+	// user to approve a signature before rejecting it. Zero selects the
+	// vault default.
+	SignApprovalTimeout int `json:"sign_approval_timeout,omitempty"`
+	// VaultIdleLock is the number of seconds without a signature after
+	// which the vault locks. Zero selects the vault default; a negative
+	// value never locks.
+	VaultIdleLock int `json:"vault_idle_lock,omitempty"`
+	// Prices overrides or extends DefaultPrices, keyed by model name.
+	Prices PriceTable `json:"prices,omitempty"`
 }
 
 func NewConfig(path string) (*Config, error) {
@@ -55,6 +91,10 @@ func (c *Config) LoadFromEnv() {
 		c.SetProviderKey("openai", key)
 	}
 
+	if url := os.Getenv("OPENAI_BASE_URL"); url != "" {
+		c.SetProviderURL("openai", url)
+	}
+
 	if model := os.Getenv("ACTIVE_MODEL"); model != "" {
 		c.SetActiveModel(model)
 	}
@@ -74,6 +114,20 @@ func (c *Config) GetProviderKey(provider string) string {
 	return c.ProviderKeys[provider]
 }
 
+func (c *Config) SetProviderURL(provider, url string) {
+	if c.ProviderURLs == nil {
+		c.ProviderURLs = make(map[string]string)
+	}
+	c.ProviderURLs[provider] = url
+}
+
+func (c *Config) GetProviderURL(provider string) string {
+	if c.ProviderURLs == nil {
+		return ""
+	}
+	return c.ProviderURLs[provider]
+}
+
 func (c *Config) SetActiveModel(model string) {
 	c.ActiveModel = model
 }
@@ -27,6 +27,12 @@ func TestConfig(t *testing.T) {
 		t.Errorf("Expected 'test-model', got '%s'", val)
 	}
 
+	// Test Set/Get ProviderURL
+	cfg.SetProviderURL("openai", "http://localhost:11434/v1")
+	if val := cfg.GetProviderURL("openai"); val != "http://localhost:11434/v1" {
+		t.Errorf("Expected 'http://localhost:11434/v1', got '%s'", val)
+	}
+
 	// Test LoadFromEnv
 	os.Setenv("GOOGLE_API_KEY", "google_env_key")
 	os.Setenv("OPENAI_API_KEY", "openai_env_key")
@@ -79,3 +85,27 @@ func TestConfig(t *testing.T) {
 		t.Errorf("Expected 'save-model', got '%s'", val)
 	}
 }
+
+func TestPriceTable(t *testing.T) {
+	cfg := &Config{Prices: PriceTable{"llama3.1": {ContextWindow: 8192}}}
+	table := cfg.PriceTable()
+
+	// Configured prices extend the defaults
+	if p, ok := table.Lookup("llama3.1"); !ok || p.ContextWindow != 8192 {
+		t.Errorf("Expected configured price for llama3.1, got %+v", p)
+	}
+
+	// Dated model names match the longest known prefix
+	p, ok := table.Lookup("gpt-4o-mini-2024-07-18")
+	if !ok || p != DefaultPrices["gpt-4o-mini"] {
+		t.Errorf("Expected gpt-4o-mini price, got %+v", p)
+	}
+
+	if _, ok := table.Lookup("unknown-model"); ok {
+		t.Error("Expected unknown model lookup to fail")
+	}
+
+	if cost := DefaultPrices["gpt-4o"].Cost(1000000, 100000); cost != 3.5 {
+		t.Errorf("Expected cost 3.5, got %f", cost)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import "strings"
+
+// ModelPrice describes the cost and context window of a model.
+// Prices are in USD per million tokens.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+	ContextWindow    int     `json:"context_window"`
+}
+
+// Cost returns the USD cost of a request with the given token counts.
+func (p ModelPrice) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// PriceTable maps model names to their prices.
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices are the list prices of the hosted models luccibot knows about.
+// Self-hosted models are free and can be given a context window in the config.
+var DefaultPrices = PriceTable{
+	"gemini-2.5-pro":        {InputPerMillion: 1.25, OutputPerMillion: 10.00, ContextWindow: 1048576},
+	"gemini-2.5-flash":      {InputPerMillion: 0.30, OutputPerMillion: 2.50, ContextWindow: 1048576},
+	"gemini-2.5-flash-lite": {InputPerMillion: 0.10, OutputPerMillion: 0.40, ContextWindow: 1048576},
+	"gpt-4o":                {InputPerMillion: 2.50, OutputPerMillion: 10.00, ContextWindow: 128000},
+	"gpt-4o-mini":           {InputPerMillion: 0.15, OutputPerMillion: 0.60, ContextWindow: 128000},
+	"gpt-4.1":               {InputPerMillion: 2.00, OutputPerMillion: 8.00, ContextWindow: 1047576},
+	"gpt-4.1-mini":          {InputPerMillion: 0.40, OutputPerMillion: 1.60, ContextWindow: 1047576},
+}
+
+// Lookup returns the price of a model. Dated or suffixed model names such as
+// "gpt-4o-2024-08-06" match the longest known prefix.
+func (t PriceTable) Lookup(model string) (ModelPrice, bool) {
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+
+	var best string
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return t[best], true
+}
+
+// PriceTable returns the default prices overridden by the configured ones.
+func (c *Config) PriceTable() PriceTable {
+	table := make(PriceTable, len(DefaultPrices)+len(c.Prices))
+	for name, p := range DefaultPrices {
+		table[name] = p
+	}
+	for name, p := range c.Prices {
+		table[name] = p
+	}
+	return table
+}
@@ -0,0 +1,237 @@
+// Package journal persists every message passing through the bus.Hub as
+// JSON lines, so that a run can be inspected or replayed after the fact.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+const (
+	// DefaultMaxSize is the size at which a journal file is rotated.
+	DefaultMaxSize = 10 << 20
+	// DefaultMaxFiles is the number of journal files kept on disk.
+	DefaultMaxFiles = 50
+)
+
+// Journal appends hub records to files named <session>-<seq>.jsonl, where
+// session is the start time of the run.
+type Journal struct {
+	// MaxSize is the size in bytes above which a new file is started.
+	MaxSize int64
+	// MaxFiles is the number of files kept; the oldest are removed on rotation.
+	MaxFiles int
+
+	dir     string
+	session string
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	seq  int
+	err  error
+}
+
+// DefaultDir returns ~/.luccibot/journal.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".luccibot", "journal"), nil
+}
+
+// Open starts a new journal session in dir. The journal holds transactions
+// in the clear, signed ones included, so dir and its files are private to
+// the user, like the keystore.
+func Open(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	// MkdirAll leaves the mode of an existing directory alone.
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to restrict journal directory: %w", err)
+	}
+	j := &Journal{
+		MaxSize:  DefaultMaxSize,
+		MaxFiles: DefaultMaxFiles,
+		dir:      dir,
+		session:  time.Now().Format("20060102-150405"),
+	}
+	if err := j.openFile(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Session returns the name of the session being written.
+func (j *Journal) Session() string {
+	return j.session
+}
+
+// Record appends a record. It matches the signature of bus.Hub.SetRecorder;
+// write failures are kept and reported by Err.
+func (j *Journal) Record(r bus.Record) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		j.setErr(fmt.Errorf("failed to encode %s record: %w", r.Kind, err))
+		return
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return
+	}
+	if j.size > 0 && j.size+int64(len(data)) > j.MaxSize {
+		if err := j.rotate(); err != nil {
+			j.err = err
+			return
+		}
+	}
+	n, err := j.file.Write(data)
+	j.size += int64(n)
+	if err != nil {
+		j.err = fmt.Errorf("failed to write journal: %w", err)
+	}
+}
+
+// Err returns the last error met while recording, if any.
+func (j *Journal) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Close closes the current file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+func (j *Journal) setErr(err error) {
+	j.mu.Lock()
+	j.err = err
+	j.mu.Unlock()
+}
+
+func (j *Journal) openFile() error {
+	path := filepath.Join(j.dir, fmt.Sprintf("%s-%03d.jsonl", j.session, j.seq))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	j.file = f
+	j.size = 0
+	return nil
+}
+
+// rotate closes the current file, starts the next one and prunes old files.
+func (j *Journal) rotate() error {
+	if err := j.file.Close(); err != nil {
+		return fmt.Errorf("failed to close journal: %w", err)
+	}
+	j.seq++
+	if err := j.openFile(); err != nil {
+		j.file = nil
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(j.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for len(files) > j.MaxFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// Sessions lists the sessions recorded in dir, oldest first.
+func Sessions(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var sessions []string
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".jsonl")
+		i := strings.LastIndex(name, "-")
+		if i < 0 {
+			continue
+		}
+		if s := name[:i]; len(sessions) == 0 || sessions[len(sessions)-1] != s {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+// ReadSession returns the records of a session in the order they were written.
+func ReadSession(dir, session string) ([]bus.Record, error) {
+	files, err := filepath.Glob(filepath.Join(dir, session+"-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("journal session %s not found", session)
+	}
+	sort.Strings(files)
+
+	var records []bus.Record
+	for _, path := range files {
+		recs, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recs...)
+	}
+	return records, nil
+}
+
+// ReadFile returns the records of a single journal file.
+func ReadFile(path string) ([]bus.Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []bus.Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r bus.Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filepath.Base(path), line, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return records, nil
+}
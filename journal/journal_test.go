@@ -0,0 +1,177 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	h := bus.NewHub()
+	h.SetRecorder(j.Record)
+
+	prompt := bus.NewEvent(bus.UserMessagePayload{Text: "swap 1 eth"})
+	h.RecordEvent(bus.RecordInbound, prompt)
+	h.RecordAction(bus.Action{RequestID: "req-a", ParentID: prompt.RequestID, SkillName: "swap.ts", Args: map[string]any{"amount": "1"}})
+	h.RecordSignRequest(bus.SignRequest{RequestID: "req-s", ParentID: "req-a", TxData: []byte(`{"to":"0x1"}`)})
+	h.RecordSignResponse(bus.SignResponse{RequestID: "req-s", Error: errors.New("vault locked")})
+	h.RecordEvent(bus.RecordOutbound, bus.LogEvent("done").WithParent(prompt.RequestID))
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	sessions, err := Sessions(dir)
+	if err != nil || len(sessions) != 1 || sessions[0] != j.Session() {
+		t.Fatalf("Expected session %s, got %v (%v)", j.Session(), sessions, err)
+	}
+	records, err := ReadSession(dir, j.Session())
+	if err != nil {
+		t.Fatalf("ReadSession failed: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("Expected 5 records, got %d", len(records))
+	}
+
+	if p, ok := records[0].Event.Payload.(bus.UserMessagePayload); !ok || p.Text != "swap 1 eth" {
+		t.Errorf("Unexpected inbound record: %+v", records[0].Event)
+	}
+	if records[1].Action.SkillName != "swap.ts" || records[1].Action.ParentID != prompt.RequestID {
+		t.Errorf("Unexpected action record: %+v", records[1].Action)
+	}
+	if string(records[2].SignRequest.TxData) != `{"to":"0x1"}` {
+		t.Errorf("Unexpected sign request record: %+v", records[2].SignRequest)
+	}
+	if records[3].Error != "vault locked" {
+		t.Errorf("Expected 'vault locked', got '%s'", records[3].Error)
+	}
+	if records[4].Time.IsZero() {
+		t.Error("Expected records to be timestamped")
+	}
+}
+
+func TestJournalPermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "journal")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer j.Close()
+
+	// Signed transactions are journaled in the clear
+	for path, want := range map[string]os.FileMode{dir: 0700, j.file.Name(): 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", path, info.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestJournalRotation(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	j.MaxSize = 200
+	j.MaxFiles = 3
+
+	for i := 0; i < 20; i++ {
+		j.Record(bus.Record{Kind: bus.RecordOutbound, Event: ptr(bus.LogEvent("line %d", i))})
+	}
+	j.Close()
+	if err := j.Err(); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files after pruning, got %d", len(files))
+	}
+	for _, f := range files {
+		if info, _ := os.Stat(f); info.Size() > 200 {
+			t.Errorf("Expected %s to be rotated at 200 bytes, got %d", f, info.Size())
+		}
+	}
+
+	// The newest records survive
+	records, err := ReadSession(dir, j.Session())
+	if err != nil {
+		t.Fatalf("ReadSession failed: %v", err)
+	}
+	if last := records[len(records)-1].Event.Payload.(bus.LogPayload); last.Message != "line 19" {
+		t.Errorf("Expected 'line 19' last, got '%s'", last.Message)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	first := bus.NewEvent(bus.UserMessagePayload{Text: "hi"})
+	second := bus.NewEvent(bus.CommandPayload{Text: "/sessions"})
+	records := []bus.Record{
+		{Kind: bus.RecordInbound, Event: &first},
+		{Kind: bus.RecordOutbound, Event: ptr(bus.LogEvent("ignored"))},
+		{Kind: bus.RecordInbound, Event: ptr(bus.NewEvent(bus.CancelPayload{}))},
+		{Kind: bus.RecordInbound, Event: &second},
+	}
+
+	// A fake agent answering every input, with a trailing log after the answer
+	h := bus.NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var handled []bus.EventType
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-h.Inbound:
+				handled = append(handled, ev.Type)
+				h.Outbound <- bus.NewEvent(bus.ResponsePayload{Text: "ok"}).WithParent(ev.RequestID)
+				h.Outbound <- bus.LogEvent("after").WithParent(ev.RequestID)
+			}
+		}
+	}()
+
+	var got []bus.Event
+	if err := Replay(ctx, h, records, time.Second, func(ev bus.Event) { got = append(got, ev) }); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if len(handled) != 2 || handled[0] != bus.EventUserMessage || handled[1] != bus.EventCommand {
+		t.Errorf("Expected the message and the command to be replayed, got %v", handled)
+	}
+	if len(got) != 4 || got[0].ParentID != first.RequestID || got[3].ParentID != second.RequestID {
+		t.Errorf("Expected 4 events linked to the recorded inputs, got %+v", got)
+	}
+}
+
+func TestReplayTimeout(t *testing.T) {
+	prompt := bus.NewEvent(bus.UserMessagePayload{Text: "hi"})
+	h := bus.NewHub()
+
+	// Nobody answers
+	err := Replay(context.Background(), h, []bus.Record{{Kind: bus.RecordInbound, Event: &prompt}}, 20*time.Millisecond, func(bus.Event) {})
+	if err == nil {
+		t.Error("Expected a timeout error")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+// settleTime is how long Replay keeps collecting events after the end of a
+// turn, e.g. errors and compaction logs that follow the final answer.
+const settleTime = 200 * time.Millisecond
+
+// Inputs returns the user messages and commands recorded in a session.
+// Cancellations are left out as their timing cannot be reproduced.
+func Inputs(records []bus.Record) []bus.Event {
+	var inputs []bus.Event
+	for _, r := range records {
+		if r.Kind != bus.RecordInbound || r.Event == nil {
+			continue
+		}
+		switch r.Event.Type {
+		case bus.EventUserMessage, bus.EventCommand:
+			inputs = append(inputs, *r.Event)
+		}
+	}
+	return inputs
+}
+
+// Replay sends the recorded inputs to h.Inbound one at a time, keeping
+// their request IDs, and hands every outbound event to out. Each input is
+// sent once the previous turn has ended. The caller runs the agent and the
+// bridge; Replay must be the only reader of h.Outbound.
+func Replay(ctx context.Context, h *bus.Hub, records []bus.Record, timeout time.Duration, out func(bus.Event)) error {
+	for _, input := range Inputs(records) {
+		select {
+		case h.Inbound <- input:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := waitTurn(ctx, h, input.RequestID, timeout, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitTurn forwards outbound events until the turn caused by requestID
+// ends and the hub has been quiet for settleTime.
+func waitTurn(ctx context.Context, h *bus.Hub, requestID string, timeout time.Duration, out func(bus.Event)) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("turn %s did not finish within %s", requestID, timeout)
+		case <-settle:
+			return nil
+		case ev := <-h.Outbound:
+			out(ev)
+			if settle != nil || (ev.ParentID == requestID && endsTurn(ev)) {
+				settle = time.After(settleTime)
+			}
+		}
+	}
+}
+
+// endsTurn reports whether the agent sends ev as the last answer to a
+// message or command.
+func endsTurn(ev bus.Event) bool {
+	switch ev.Payload.(type) {
+	case bus.ResponseDonePayload, bus.ResponsePayload, bus.ErrorPayload:
+		return true
+	}
+	return false
+}
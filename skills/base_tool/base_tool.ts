@@ -0,0 +1,44 @@
+// base_tool.ts
+// This is a sample skill that simulates generating a transaction.
+// It speaks the bridge protocol described in docs/skill-protocol.md.
+
+interface Transaction {
+  to: string;
+  value: string;
+  data: string;
+  nonce: number;
+}
+
+interface SkillRequest {
+  version: number;
+  request_id: string;
+  action: string;
+  params: Record<string, unknown>;
+  chain?: string;
+  account?: string;
+}
+
+// Every message is one JSON line on stdout.
+function send(message: Record<string, unknown>) {
+  process.stdout.write(JSON.stringify({ version: 1, ...message }) + "\n");
+}
+
+async function readRequest(): Promise<SkillRequest> {
+  let input = "";
+  for await (const chunk of process.stdin) {
+    input += chunk;
+  }
+  return JSON.parse(input);
+}
+
+const request = await readRequest();
+send({ type: "progress", message: "building transaction" });
+
+const mockTx: Transaction = {
+  to: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+  value: "1000000000000000000", // 1 ETH in Wei
+  data: "0x",
+  nonce: 42,
+};
+
+send({ type: "tx_request", chain: request.chain, tx: mockTx });
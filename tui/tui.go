@@ -1,9 +1,13 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -71,6 +75,10 @@ var (
 			Foreground(mutedColor).
 			MarginTop(1)
 
+	traceStyle = lipgloss.NewStyle().
+			Foreground(primaryColor).
+			MarginTop(1)
+
 	errorMsgStyle = lipgloss.NewStyle().
 			Foreground(errorColor).
 			Bold(true).
@@ -106,6 +114,17 @@ var (
 			Padding(0, 1).
 			MarginRight(1)
 
+	// Signature approval modal
+	modalStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(primaryColor).
+			Foreground(textColor).
+			Padding(1, 2)
+
+	modalTitleStyle = lipgloss.NewStyle().
+			Foreground(primaryColor).
+			Bold(true)
+
 	// Model info bar (below input)
 	modelInfoStyle = lipgloss.NewStyle().
 			Padding(0, 2)
@@ -118,10 +137,13 @@ var (
 )
 
 type Model struct {
-	hub          *bus.Hub
-	textInput    textinput.Model
-	viewport     viewport.Model
-	messages     []string
+	hub       *bus.Hub
+	events    *bus.Subscription
+	textInput textinput.Model
+	viewport  viewport.Model
+	messages  []*entry
+	// parents maps the RequestID of every event seen to its ParentID.
+	parents      map[string]string
 	err          error
 	width        int
 	height       int
@@ -129,6 +151,48 @@ type Model struct {
 	inputFocused bool
 	modelName    string
 	provider     string
+
+	// streams maps a streamed message ID to its bubble.
+	streams map[string]*stream
+	// generating is the ID of the message being generated, if any.
+	generating string
+	spinner    spinner.Model
+	// usage holds the latest token and cost stats shown in the header.
+	usage bus.UsageStats
+	// skills holds the latest bridge queue depth shown in the status bar.
+	skills bus.SkillStatusPayload
+	// vault holds the latest vault state shown in the status bar.
+	vault bus.VaultStatusPayload
+	// unlocking shows the masked passphrase prompt in place of the input.
+	unlocking bool
+	passInput textinput.Model
+	// approvals queues the signatures waiting for the user's decision;
+	// the first one is shown in a modal.
+	approvals []bus.SignApprovalPayload
+	// approvalShown is when the first of approvals was shown, and
+	// approveArmed whether y was pressed on it and enter would approve it.
+	approvalShown time.Time
+	approveArmed  bool
+}
+
+// approvalGrace is how long keys are ignored after an approval modal opens,
+// since it opens on its own while the user may be typing.
+const approvalGrace = 750 * time.Millisecond
+
+// unlockResultMsg is the vault's answer to a passphrase.
+type unlockResultMsg struct{ err error }
+
+// entry is a rendered message. group is the RequestID of the user message
+// that caused it, or of the entry itself when it has no known cause.
+type entry struct {
+	group string
+	text  string
+}
+
+// stream is a bot message updated in place as deltas arrive.
+type stream struct {
+	entry *entry
+	text  string
 }
 
 func NewModel(h *bus.Hub) Model {
@@ -139,20 +203,49 @@ func NewModel(h *bus.Hub) Model {
 	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(mutedColor)
 	ti.Cursor.Style = lipgloss.NewStyle().Foreground(primaryColor)
 
+	pi := textinput.New()
+	pi.Prompt = "Vault passphrase: "
+	pi.EchoMode = textinput.EchoPassword
+	pi.EchoCharacter = '•'
+	pi.CharLimit = 256
+	pi.TextStyle = ti.TextStyle
+	pi.Cursor.Style = ti.Cursor.Style
+
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+	sp.Style = lipgloss.NewStyle().Foreground(secondaryColor)
+
+	// The TUI must not lose stream deltas, so it blocks publishers rather
+	// than dropping events.
+	events := h.Subscribe(bus.SubscribeOptions{Policy: bus.SlowBlock, Buffer: 256})
+
 	return Model{
 		hub:          h,
+		events:       events,
 		textInput:    ti,
-		messages:     []string{},
+		messages:     []*entry{},
+		parents:      make(map[string]string),
 		inputFocused: true,
 		modelName:    "Gemini 2.5 Pro",
 		provider:     "Google",
+		streams:      make(map[string]*stream),
+		spinner:      sp,
+		vault:        bus.VaultStatusPayload{Locked: true},
+		passInput:    pi,
 	}
 }
 
+// WithModelInfo returns a copy of the model showing the given LLM model and provider.
+func (m Model) WithModelInfo(modelName, provider string) Model {
+	m.modelName = modelName
+	m.provider = provider
+	return m
+}
+
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		textinput.Blink,
-		waitForActivity(m.hub.Outbound),
+		waitForActivity(m.events.C()),
 	)
 }
 
@@ -189,9 +282,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.viewport.GotoBottom()
 
 	case tea.KeyMsg:
+		if len(m.approvals) > 0 {
+			return m.updateApproval(msg)
+		}
+		if m.unlocking {
+			return m.updateUnlock(msg)
+		}
 		switch msg.Type {
 		case tea.KeyCtrlC:
 			return m, tea.Quit
+		case tea.KeyCtrlZ:
+			// Never leave the vault unlocked behind a suspended terminal
+			return m, tea.Sequence(lockVault(m.hub, bus.VaultSuspend), tea.Suspend)
+		case tea.KeyCtrlX:
+			// Cancel the running generation
+			if m.generating != "" {
+				go func() {
+					m.hub.Inbound <- bus.NewEvent(bus.CancelPayload{})
+				}()
+			}
+			return m, nil
 		case tea.KeyEsc:
 			if m.inputFocused {
 				m.inputFocused = false
@@ -214,15 +324,29 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if strings.TrimSpace(v) == "" {
 					return m, nil
 				}
-				// Add user message to UI
-				m.messages = append(m.messages, m.formatUserMessage(v))
+				// /unlock and /lock are handled here, so that the
+				// passphrase never travels as an event
+				switch strings.TrimSpace(v) {
+				case "/unlock":
+					m.textInput.SetValue("")
+					return m.openUnlock(), nil
+				case "/lock":
+					m.textInput.SetValue("")
+					return m, lockVault(m.hub, bus.VaultUser)
+				}
+				// Slash commands (/new, /sessions, /resume <id>) are handled by the agent;
+				// /cancel [action id] kills running skills
+				event := bus.NewEvent(bus.UserMessagePayload{Text: v})
+				if strings.HasPrefix(strings.TrimSpace(v), "/") {
+					event = commandEvent(strings.TrimSpace(v))
+				}
+
+				// Add user message to UI; everything it causes is grouped under it
+				m.add(event, m.formatUserMessage(v))
 
 				// Send to Hub
 				go func() {
-					m.hub.Inbound <- bus.Event{
-						Type:    "user_message",
-						Payload: v,
-					}
+					m.hub.Inbound <- event
 				}()
 
 				m.textInput.SetValue("")
@@ -234,31 +358,132 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case bus.Event:
 		// Handle incoming events from the Hub
-		switch msg.Type {
-		case "log":
-			if payload, ok := msg.Payload.(string); ok {
-				m.messages = append(m.messages, m.formatLogMessage(payload))
+		switch p := msg.Payload.(type) {
+		case bus.LogPayload:
+			m.add(msg, m.formatLogMessage(p.Message))
+		case bus.TracePayload:
+			m.add(msg, m.formatTraceMessage(fmt.Sprintf("[%s] %s", p.Specialist, p.Step)))
+		case bus.ResponsePayload:
+			m.add(msg, m.formatBotMessage(p.Text))
+		case bus.UsageStats:
+			m.usage = p
+			return m, waitForActivity(m.events.C())
+		case bus.ThinkingPayload:
+			s := &stream{}
+			m.streams[p.MessageID] = s
+			m.generating = p.MessageID
+			s.entry = m.add(msg, m.formatStream(p.MessageID))
+			m.viewport.SetContent(m.renderMessages())
+			m.viewport.GotoBottom()
+			return m, tea.Batch(waitForActivity(m.events.C()), m.spinner.Tick)
+		case bus.ResponseDeltaPayload:
+			if s, ok := m.streams[p.MessageID]; ok {
+				s.text += p.Text
+				s.entry.text = m.formatStream(p.MessageID)
+			}
+		case bus.ResponseDonePayload:
+			if s, ok := m.streams[p.MessageID]; ok {
+				if p.Text != "" {
+					s.text = p.Text
+				}
+				if m.generating == p.MessageID {
+					m.generating = ""
+				}
+				s.entry.text = m.formatStream(p.MessageID)
+				delete(m.streams, p.MessageID)
 			}
-		case "response":
-			if payload, ok := msg.Payload.(string); ok {
-				m.messages = append(m.messages, m.formatBotMessage(payload))
+		case bus.TxSignedPayload:
+			m.add(msg, m.formatSuccessMessage(fmt.Sprintf("Transaction %s signed by %s. Signature: %s", p.TxHash, p.Skill, p.Signature)))
+		case bus.ErrorPayload:
+			m.add(msg, m.formatErrorMessage(p.Message))
+		case bus.UserMessagePayload:
+			m.add(msg, m.formatUserMessage(p.Text))
+		case bus.CommandPayload:
+			m.add(msg, m.formatUserMessage(p.Text))
+		case bus.CancelPayload:
+			m.add(msg, m.formatLogMessage("Cancel requested."))
+		case bus.CancelSkillPayload:
+			m.add(msg, m.formatLogMessage("Skill cancel requested."))
+		case bus.VaultStatusPayload:
+			m.vault = p
+			switch {
+			case !p.Locked:
+				m.add(msg, m.formatSuccessMessage("Vault unlocked, signing as "+p.Account))
+			case p.Reason == bus.VaultSignRejected:
+				m.add(msg, m.formatErrorMessage("A signature was requested but the vault is locked. Unlock it and retry."))
+				m = m.openUnlock()
+			case p.Reason == bus.VaultIdle:
+				m.add(msg, m.formatLogMessage("Vault locked after inactivity."))
+			case p.Reason == bus.VaultSuspend:
+				m.add(msg, m.formatLogMessage("Vault locked on suspend."))
+			default:
+				m.add(msg, m.formatLogMessage("Vault locked. Type /unlock to sign transactions."))
 			}
-		case "signed":
-			m.messages = append(m.messages, m.formatSuccessMessage("Transaction Signed Successfully!"))
-		case "error":
-			if payload, ok := msg.Payload.(string); ok {
-				m.messages = append(m.messages, m.formatErrorMessage(payload))
+		case bus.SignApprovalPayload:
+			if len(m.approvals) == 0 {
+				m.approvalShown, m.approveArmed = time.Now(), false
+			}
+			m.approvals = append(m.approvals, p)
+			m.add(msg, m.formatLogMessage(fmt.Sprintf("Signature requested: %s to %s on %s", formatAmount(p.Amount, p.Token), p.To, p.Chain)))
+		case bus.SignDecisionPayload:
+			shown := len(m.approvals) > 0 && m.approvals[0].SignRequestID == p.SignRequestID
+			m.approvals = slices.DeleteFunc(m.approvals, func(a bus.SignApprovalPayload) bool {
+				return a.SignRequestID == p.SignRequestID
+			})
+			if shown {
+				// The next signature waiting, if any, opens in its place.
+				m.approvalShown, m.approveArmed = time.Now(), false
+			}
+			switch {
+			case p.Approved:
+				m.add(msg, m.formatSuccessMessage("Signature approved."))
+			case p.TimedOut:
+				m.add(msg, m.formatErrorMessage("Signature rejected: it was not approved in time."))
+			default:
+				m.add(msg, m.formatLogMessage("Signature rejected."))
+			}
+		case bus.SkillStatusPayload:
+			m.skills = p
+			switch p.Status {
+			case bus.SkillQueued:
+				m.add(msg, m.formatLogMessage(fmt.Sprintf("Queued skill %s (%d waiting)", p.Skill, p.Queued)))
+			case bus.SkillRunning:
+				m.add(msg, m.formatLogMessage(fmt.Sprintf("Running skill %s", p.Skill)))
+			default:
+				m.add(msg, m.formatLogMessage(fmt.Sprintf("Finished skill %s", p.Skill)))
 			}
 		default:
-			m.messages = append(m.messages, m.formatLogMessage(fmt.Sprintf("Event: %s", msg.Type)))
+			m.add(msg, m.formatLogMessage(fmt.Sprintf("Event: %s", msg.Type)))
 		}
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
-		return m, waitForActivity(m.hub.Outbound)
+		return m, waitForActivity(m.events.C())
+
+	case unlockResultMsg:
+		if msg.err != nil {
+			m.add(bus.Event{}, m.formatErrorMessage("Unlock failed: "+msg.err.Error()))
+			m = m.openUnlock()
+		}
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		return m, nil
+
+	case spinner.TickMsg:
+		// Animate the thinking indicator only while generating
+		if m.generating == "" {
+			return m, nil
+		}
+		var spCmd tea.Cmd
+		m.spinner, spCmd = m.spinner.Update(msg)
+		if s, ok := m.streams[m.generating]; ok {
+			s.entry.text = m.formatStream(m.generating)
+			m.viewport.SetContent(m.renderMessages())
+		}
+		return m, spCmd
 
 	case error:
 		m.err = msg
-		m.messages = append(m.messages, m.formatErrorMessage(msg.Error()))
+		m.add(bus.Event{}, m.formatErrorMessage(msg.Error()))
 		m.viewport.SetContent(m.renderMessages())
 		return m, nil
 	}
@@ -269,13 +494,92 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Update text input
-	if m.inputFocused {
+	if m.unlocking {
+		m.passInput, taCmd = m.passInput.Update(msg)
+	} else if m.inputFocused {
 		m.textInput, taCmd = m.textInput.Update(msg)
 	}
 
 	return m, tea.Batch(vpCmd, taCmd)
 }
 
+// updateApproval handles keys while a signature waits for approval: y then
+// enter approves it, n or esc rejects it, and any other key takes back a y.
+// Keys other than ctrl+c and ctrl+z are ignored for approvalGrace after the
+// modal opens. The modal stays until the vault reports the decision.
+func (m Model) updateApproval(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	id := m.approvals[0].SignRequestID
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyCtrlZ:
+		return m, tea.Sequence(lockVault(m.hub, bus.VaultSuspend), tea.Suspend)
+	}
+	if time.Since(m.approvalShown) < approvalGrace {
+		return m, nil
+	}
+	armed := m.approveArmed
+	m.approveArmed = false
+	switch msg.Type {
+	case tea.KeyEsc:
+		return m, decideSign(m.hub, id, false)
+	case tea.KeyEnter:
+		if armed {
+			return m, decideSign(m.hub, id, true)
+		}
+	case tea.KeyRunes:
+		switch strings.ToLower(msg.String()) {
+		case "y":
+			m.approveArmed = true
+		case "n":
+			return m, decideSign(m.hub, id, false)
+		}
+	}
+	return m, nil
+}
+
+// openUnlock shows the passphrase prompt in place of the input.
+func (m Model) openUnlock() Model {
+	m.unlocking = true
+	m.passInput.Reset()
+	m.passInput.Focus()
+	m.textInput.Blur()
+	return m
+}
+
+// closeUnlock restores the input.
+func (m Model) closeUnlock() Model {
+	m.unlocking = false
+	m.passInput.Reset()
+	m.passInput.Blur()
+	if m.inputFocused {
+		m.textInput.Focus()
+	}
+	return m
+}
+
+// updateUnlock handles keys while the passphrase prompt is shown: enter
+// sends the passphrase to the vault, esc dismisses the prompt.
+func (m Model) updateUnlock(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyCtrlZ:
+		return m.closeUnlock(), tea.Sequence(lockVault(m.hub, bus.VaultSuspend), tea.Suspend)
+	case tea.KeyEsc:
+		return m.closeUnlock(), nil
+	case tea.KeyEnter:
+		passphrase := m.passInput.Value()
+		if passphrase == "" {
+			return m, nil
+		}
+		return m.closeUnlock(), unlockVault(m.hub, passphrase)
+	}
+	var cmd tea.Cmd
+	m.passInput, cmd = m.passInput.Update(msg)
+	return m, cmd
+}
+
 func (m Model) View() string {
 	if !m.ready {
 		return "Initializing..."
@@ -284,15 +588,23 @@ func (m Model) View() string {
 	// Header
 	header := m.renderHeader()
 
-	// Messages viewport
+	// Messages viewport, or the signature waiting for approval
+	messages := m.viewport.View()
+	if len(m.approvals) > 0 {
+		messages = lipgloss.Place(m.viewport.Width, m.viewport.Height, lipgloss.Center, lipgloss.Center, m.renderApproval())
+	}
 	messagesArea := messageContainerStyle.
 		Width(m.width - 4).
-		Render(m.viewport.View())
+		Render(messages)
 
-	// Input area with prompt
+	// Input area with prompt, or the passphrase prompt
+	input := m.textInput.View()
+	if m.unlocking {
+		input = m.passInput.View()
+	}
 	inputArea := inputContainerStyle.
 		Width(m.width - 4).
-		Render(m.textInput.View())
+		Render(input)
 
 	// Status bar
 	statusBar := m.renderStatusBar()
@@ -307,12 +619,57 @@ func (m Model) View() string {
 	)
 }
 
+// renderApproval renders the modal of the first signature waiting for
+// approval.
+func (m Model) renderApproval() string {
+	a := m.approvals[0]
+	to := a.To
+	if to == "" {
+		to = "new contract"
+	}
+	calldata := a.Calldata
+	if calldata == "" {
+		calldata = "none"
+	}
+	rows := [][2]string{
+		{"From", a.From},
+		{"To", to},
+		{"Amount", formatAmount(a.Amount, a.Token)},
+		{"Chain", fmt.Sprintf("%s (%s)", a.Chain, a.ChainID)},
+		{"Max gas", a.GasCost + " " + a.GasToken},
+		{"Calldata", calldata},
+	}
+	lines := []string{modalTitleStyle.Render("Approve signature?"), ""}
+	for _, r := range rows {
+		lines = append(lines, systemLabelStyle.Render(fmt.Sprintf("%-9s", r[0]))+" "+r[1])
+	}
+	keys := "y then enter approve • n reject"
+	if m.approveArmed {
+		keys = "enter to approve • any other key cancels"
+	}
+	footer := fmt.Sprintf("%s • rejected automatically at %s", keys, a.Expires.Local().Format("15:04:05"))
+	if n := len(m.approvals) - 1; n > 0 {
+		footer += fmt.Sprintf(" • %d more waiting", n)
+	}
+	lines = append(lines, "", statusTextStyle.Render(footer))
+	return modalStyle.Render(strings.Join(lines, "\n"))
+}
+
+// formatAmount formats an amount of a native coin, or of base units of the
+// ERC-20 token at address token.
+func formatAmount(amount, token string) string {
+	if strings.HasPrefix(token, "0x") {
+		return fmt.Sprintf("%s base units of token %s", amount, token)
+	}
+	return amount + " " + token
+}
+
 func (m Model) renderHeader() string {
 	title := headerTitleStyle.Render("luccibot")
 	version := statusTextStyle.Render(" v0.1.0")
 
 	leftSide := title + version
-	rightSide := statusTextStyle.Render(`27,913  3% ($0.00)`)
+	rightSide := statusTextStyle.Render(m.renderUsage())
 
 	spaces := m.width - lipgloss.Width(leftSide) - lipgloss.Width(rightSide) - 4
 	if spaces < 0 {
@@ -324,6 +681,31 @@ func (m Model) renderHeader() string {
 		Render(leftSide + strings.Repeat(" ", spaces) + rightSide)
 }
 
+// renderUsage formats the session tokens, context window utilization and spend,
+// e.g. "27,913  3% ($0.04 · today $1.20)".
+func (m Model) renderUsage() string {
+	u := m.usage
+	parts := []string{formatThousands(u.SessionTokens)}
+	if u.ContextWindow > 0 {
+		parts = append(parts, fmt.Sprintf("%d%%", u.ContextTokens*100/u.ContextWindow))
+	}
+	cost := fmt.Sprintf("($%.2f", u.SessionCost)
+	if u.DayCost > 0 {
+		cost += fmt.Sprintf(" · today $%.2f", u.DayCost)
+	}
+	parts = append(parts, cost+")")
+	return strings.Join(parts, "  ")
+}
+
+// formatThousands renders n with comma separators.
+func formatThousands(n int) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
 func (m Model) renderStatusBar() string {
 	// Left side: model info
 	modelName := modelNameStyle.Render(m.modelName)
@@ -332,7 +714,27 @@ func (m Model) renderStatusBar() string {
 
 	// Right side: mode/status
 	mode := statusKeyStyle.Render("CHAT")
-	rightSide := mode + statusTextStyle.Render(" tab switch focus • esc blur/quit • ctrl+c quit")
+	hints := " tab switch focus • esc blur/quit • ctrl+c quit"
+	if m.generating != "" {
+		mode = statusKeyStyle.Render("THINKING")
+		hints = " ctrl+x cancel" + hints
+	}
+	if len(m.approvals) > 0 {
+		mode = statusKeyStyle.Render("APPROVE")
+		hints = " y approve • n reject • ctrl+c quit"
+	} else if m.unlocking {
+		mode = statusKeyStyle.Render("UNLOCK")
+		hints = " enter unlock • esc dismiss • ctrl+c quit"
+	}
+	if m.vault.Locked {
+		hints = " vault locked •" + hints
+	} else {
+		hints = " vault unlocked •" + hints
+	}
+	if m.skills.Running > 0 || m.skills.Queued > 0 {
+		hints = fmt.Sprintf(" skills %d running, %d queued •", m.skills.Running, m.skills.Queued) + hints
+	}
+	rightSide := mode + statusTextStyle.Render(hints)
 
 	spaces := m.width - lipgloss.Width(leftSide) - lipgloss.Width(rightSide) - 4
 	if spaces < 0 {
@@ -349,7 +751,48 @@ func (m Model) renderMessages() string {
 		return logStyle.Render("No messages yet. Type a command to get started!")
 	}
 
-	return strings.Join(m.messages, "\n")
+	lines := make([]string, len(m.messages))
+	for i, e := range m.messages {
+		lines[i] = e.text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// add renders an entry for the event, placing it after the last entry of
+// the user message that caused it.
+func (m *Model) add(ev bus.Event, text string) *entry {
+	if ev.RequestID != "" && ev.ParentID != "" {
+		m.parents[ev.RequestID] = ev.ParentID
+	}
+	e := &entry{group: m.rootOf(ev), text: text}
+
+	if e.group != "" {
+		for i := len(m.messages) - 1; i >= 0; i-- {
+			if m.messages[i].group == e.group {
+				m.messages = slices.Insert(m.messages, i+1, e)
+				return e
+			}
+		}
+	}
+	m.messages = append(m.messages, e)
+	return e
+}
+
+// rootOf follows the ParentID chain of the event to the request that started it.
+func (m *Model) rootOf(ev bus.Event) string {
+	id := ev.RequestID
+	if ev.ParentID != "" {
+		id = ev.ParentID
+	}
+	// The depth limit guards against cycles.
+	for range 32 {
+		parent, ok := m.parents[id]
+		if !ok {
+			break
+		}
+		id = parent
+	}
+	return id
 }
 
 func (m Model) formatUserMessage(content string) string {
@@ -366,10 +809,30 @@ func (m Model) formatBotMessage(content string) string {
 	return label + "\n" + box
 }
 
+// formatStream renders a streamed bot message, with a thinking indicator
+// while it is still being generated.
+func (m Model) formatStream(id string) string {
+	s := m.streams[id]
+	content := s.text
+	if m.generating == id {
+		indicator := m.spinner.View() + " thinking..."
+		if content == "" {
+			content = indicator
+		} else {
+			content += " " + m.spinner.View()
+		}
+	}
+	return m.formatBotMessage(content)
+}
+
 func (m Model) formatLogMessage(content string) string {
 	return logStyle.Render("→ " + content)
 }
 
+func (m Model) formatTraceMessage(content string) string {
+	return traceStyle.Render("⇢ " + content)
+}
+
 func (m Model) formatErrorMessage(content string) string {
 	return errorMsgStyle.Render("✗ Error: " + content)
 }
@@ -378,9 +841,81 @@ func (m Model) formatSuccessMessage(content string) string {
 	return successMsgStyle.Render("✓ " + content)
 }
 
-// waitForActivity listens on the Outbound channel and returns a tea.Msg when an event arrives.
+// commandEvent builds the event for a slash command. "/cancel [action id]"
+// goes straight to the bridge through the agent's input loop, since the
+// agent runs other commands only after the running turn.
+func commandEvent(input string) bus.Event {
+	fields := strings.Fields(input)
+	if fields[0] == "/cancel" {
+		p := bus.CancelSkillPayload{}
+		if len(fields) > 1 {
+			p.ActionID = fields[1]
+		}
+		return bus.NewEvent(p)
+	}
+	return bus.NewEvent(bus.CommandPayload{Text: input})
+}
+
+// vaultTimeout bounds the wait for the vault to answer a VaultControl.
+const vaultTimeout = 5 * time.Second
+
+// unlockVault sends a passphrase to the vault and reports its answer as an
+// unlockResultMsg.
+func unlockVault(h *bus.Hub, passphrase string) tea.Cmd {
+	return func() tea.Msg {
+		return unlockResultMsg{err: controlVault(h, bus.VaultControl{Action: bus.VaultUnlock, Passphrase: passphrase})}
+	}
+}
+
+// lockVault asks the vault to lock and waits until it has.
+func lockVault(h *bus.Hub, reason bus.VaultReason) tea.Cmd {
+	return func() tea.Msg {
+		if err := controlVault(h, bus.VaultControl{Action: bus.VaultLock, Reason: reason}); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// decideSign sends the user's decision on a signature to the vault.
+func decideSign(h *bus.Hub, signRequestID string, approved bool) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case h.SignDecisions <- bus.SignDecision{SignRequestID: signRequestID, Approved: approved}:
+			return nil
+		case <-time.After(vaultTimeout):
+			return errors.New("vault is not responding")
+		}
+	}
+}
+
+// controlVault sends a lock or unlock to the vault and waits for its answer,
+// giving up after vaultTimeout.
+func controlVault(h *bus.Hub, c bus.VaultControl) error {
+	resp := make(chan error, 1)
+	c.ResponseChan = resp
+	timeout := time.After(vaultTimeout)
+	select {
+	case h.VaultCtl <- c:
+	case <-timeout:
+		return errors.New("vault is not responding")
+	}
+	select {
+	case err := <-resp:
+		return err
+	case <-timeout:
+		return errors.New("vault is not responding")
+	}
+}
+
+// waitForActivity listens on the TUI's hub subscription and returns a tea.Msg when an event arrives.
 func waitForActivity(sub <-chan bus.Event) tea.Cmd {
 	return func() tea.Msg {
-		return <-sub
+		ev, ok := <-sub
+		if !ok {
+			// The hub shut down
+			return nil
+		}
+		return ev
 	}
 }
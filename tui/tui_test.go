@@ -0,0 +1,212 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lucci-labs/luccibot/bus"
+)
+
+func TestEveryEventRenders(t *testing.T) {
+	for _, typ := range bus.EventTypes() {
+		var ev bus.Event
+		if err := json.Unmarshal([]byte(fmt.Sprintf(`{"type":%q,"payload":{}}`, typ)), &ev); err != nil {
+			t.Fatalf("%s: unmarshal failed: %v", typ, err)
+		}
+
+		var m tea.Model = NewModel(bus.NewHub())
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+		m, _ = m.Update(ev)
+
+		// Unhandled events fall back to a generic "Event: <type>" line
+		for _, line := range m.(Model).messages {
+			if strings.Contains(line.text, "Event: ") {
+				t.Errorf("%s: event is not rendered by the TUI", typ)
+			}
+		}
+	}
+}
+
+func TestTxSignedRendering(t *testing.T) {
+	var m tea.Model = NewModel(bus.NewHub())
+	m, _ = m.Update(bus.NewEvent(bus.TxSignedPayload{Skill: "swap.ts", Signature: "0xsig"}))
+
+	messages := m.(Model).messages
+	if len(messages) != 1 || !strings.Contains(messages[0].text, "0xsig") {
+		t.Errorf("Expected the signature to be shown, got %q", m.(Model).renderMessages())
+	}
+}
+
+func TestEventsGroupedUnderPrompt(t *testing.T) {
+	var m tea.Model = NewModel(bus.NewHub())
+
+	// Two prompts, as sent by the TUI on enter
+	first := bus.NewEvent(bus.UserMessagePayload{Text: "swap 1 eth"})
+	second := bus.NewEvent(bus.UserMessagePayload{Text: "balance?"})
+	model := m.(Model)
+	model.add(first, "first")
+	model.add(second, "second")
+	m = model
+
+	// A log for the first prompt, then the bridge's chain: action, sign request, signature
+	action := bus.LogEvent("Running skill swap.ts").WithParent(first.RequestID)
+	sign := bus.LogEvent("Requesting signature").WithParent(action.RequestID)
+	signed := bus.NewEvent(bus.TxSignedPayload{Skill: "swap.ts", Signature: "0xsig"}).WithParent(sign.RequestID)
+	unrelated := bus.LogEvent("Agent started.")
+	for _, ev := range []bus.Event{action, sign, signed, unrelated} {
+		m, _ = m.Update(ev)
+	}
+
+	var got []string
+	for _, e := range m.(Model).messages {
+		got = append(got, e.group)
+	}
+	want := []string{first.RequestID, first.RequestID, first.RequestID, first.RequestID, second.RequestID, unrelated.RequestID}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected groups %v, got %v", want, got)
+	}
+}
+
+func TestCommandEvent(t *testing.T) {
+	if p, ok := commandEvent("/cancel req-1").Payload.(bus.CancelSkillPayload); !ok || p.ActionID != "req-1" {
+		t.Errorf("Expected cancel_skill for req-1, got %+v", p)
+	}
+	if p, ok := commandEvent("/cancel").Payload.(bus.CancelSkillPayload); !ok || p.ActionID != "" {
+		t.Errorf("Expected cancel_skill for all skills, got %+v", p)
+	}
+	if p, ok := commandEvent("/resume abc").Payload.(bus.CommandPayload); !ok || p.Text != "/resume abc" {
+		t.Errorf("Expected command event, got %+v", p)
+	}
+}
+
+func TestUnlockPrompt(t *testing.T) {
+	h := bus.NewHub()
+	var m tea.Model = NewModel(h)
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+
+	// A signature refused by the locked vault opens the prompt
+	m, _ = m.Update(bus.NewEvent(bus.VaultStatusPayload{Locked: true, Reason: bus.VaultSignRejected}))
+	if !m.(Model).unlocking {
+		t.Fatal("Expected a locked vault to open the unlock prompt")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("hunter2")})
+	if strings.Contains(m.View(), "hunter2") {
+		t.Error("Expected the passphrase to be masked")
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.(Model).unlocking || cmd == nil {
+		t.Fatal("Expected enter to submit the passphrase")
+	}
+
+	done := make(chan tea.Msg)
+	go func() { done <- cmd() }()
+	c := <-h.VaultCtl
+	if c.Action != bus.VaultUnlock || c.Passphrase != "hunter2" {
+		t.Errorf("Expected an unlock with the passphrase, got %+v", c)
+	}
+	c.ResponseChan <- errors.New("wrong passphrase")
+
+	// A wrong passphrase asks again
+	m, _ = m.Update(<-done)
+	if !m.(Model).unlocking || !strings.Contains(m.(Model).renderMessages(), "wrong passphrase") {
+		t.Error("Expected a failed unlock to show the error and ask again")
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.(Model).unlocking {
+		t.Error("Expected esc to dismiss the prompt")
+	}
+}
+
+func TestLockCommand(t *testing.T) {
+	h := bus.NewHub()
+	var m tea.Model = NewModel(h)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/lock")})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("Expected /lock to lock the vault")
+	}
+
+	go cmd()
+	c := <-h.VaultCtl
+	if c.Action != bus.VaultLock || c.Reason != bus.VaultUser {
+		t.Errorf("Expected a lock by the user, got %+v", c)
+	}
+	c.ResponseChan <- nil
+	select {
+	case ev := <-h.Inbound:
+		t.Errorf("Expected /lock not to reach the agent, got %+v", ev)
+	default:
+	}
+}
+
+func TestSignApprovalModal(t *testing.T) {
+	h := bus.NewHub()
+	var m tea.Model = NewModel(h)
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+
+	approval := bus.SignApprovalPayload{
+		SignRequestID: "req-1", From: "0xabc", Chain: "Base", ChainID: "8453",
+		To: "0xdef", Amount: "1000000", Token: "0x8335", GasCost: "0.00006", GasToken: "ETH",
+		Calldata: "transfer(address,uint256)", Expires: time.Now().Add(time.Minute),
+	}
+	m, _ = m.Update(bus.NewEvent(approval).WithParent("req-1"))
+	view := m.View()
+	for _, want := range []string{"Approve signature?", "0xdef", "1000000 base units of token 0x8335", "Base (8453)", "0.00006 ETH", "transfer(address,uint256)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the modal to show %q", want)
+		}
+	}
+
+	// Keys typed as the modal opens go neither to it nor to the input
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m, cmd2 := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.(Model).textInput.Value() != "" || cmd != nil || cmd2 != nil || m.(Model).approveArmed {
+		t.Fatal("Expected keys right after the modal opens to be ignored")
+	}
+	shown := m.(Model)
+	shown.approvalShown = time.Now().Add(-approvalGrace)
+	m = shown
+
+	// y alone does not approve, and another key takes it back
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m, cmd2 = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil || cmd2 != nil {
+		t.Fatal("Expected enter after a cancelled y not to approve")
+	}
+
+	// y then enter approves
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if !strings.Contains(m.View(), "enter to approve") {
+		t.Error("Expected the modal to ask for enter")
+	}
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.(Model).textInput.Value() != "" || cmd == nil {
+		t.Fatal("Expected y then enter to approve the signature")
+	}
+	cmd()
+	if d := <-h.SignDecisions; d.SignRequestID != "req-1" || !d.Approved {
+		t.Errorf("Expected approval of req-1, got %+v", d)
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	cmd()
+	if d := <-h.SignDecisions; d.Approved {
+		t.Errorf("Expected esc to reject, got %+v", d)
+	}
+
+	// The modal closes once the vault reports the decision
+	m, _ = m.Update(bus.NewEvent(bus.SignDecisionPayload{SignRequestID: "req-1", TimedOut: true}))
+	if len(m.(Model).approvals) != 0 || strings.Contains(m.View(), "Approve signature?") {
+		t.Error("Expected the decision to close the modal")
+	}
+	if !strings.Contains(m.(Model).renderMessages(), "not approved in time") {
+		t.Error("Expected the timeout to be shown")
+	}
+}
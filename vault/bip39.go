@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// bip39English is the English wordlist of BIP-39.
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+var (
+	bip39Words = strings.Fields(bip39English)
+	bip39Index = func() map[string]int {
+		m := make(map[string]int, len(bip39Words))
+		for i, w := range bip39Words {
+			m[w] = i
+		}
+		return m
+	}()
+)
+
+// NewMnemonic returns a BIP-39 mnemonic of fresh entropy: 128 bits give 12
+// words, 256 bits 24.
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("entropy of %d bits; use 128, 160, 192, 224 or 256", bits)
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	defer clear(entropy)
+	return entropyMnemonic(entropy), nil
+}
+
+// entropyMnemonic encodes entropy and its SHA-256 checksum as words of 11
+// bits each.
+func entropyMnemonic(entropy []byte) string {
+	checksumBits := len(entropy) / 4
+	sum := sha256.Sum256(entropy)
+	n := new(big.Int).SetBytes(entropy)
+	n.Lsh(n, uint(checksumBits))
+	n.Or(n, big.NewInt(int64(sum[0]>>(8-checksumBits))))
+
+	words := make([]string, (len(entropy)*8+checksumBits)/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = bip39Words[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 11)
+	}
+	return strings.Join(words, " ")
+}
+
+// ValidateMnemonic checks the words and checksum of a BIP-39 mnemonic.
+func ValidateMnemonic(mnemonic string) error {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return fmt.Errorf("mnemonic of %d words; use 12, 15, 18, 21 or 24", len(words))
+	}
+	n := new(big.Int)
+	for _, w := range words {
+		i, ok := bip39Index[w]
+		if !ok {
+			return fmt.Errorf("%q is not a BIP-39 word", w)
+		}
+		n.Lsh(n, 11)
+		n.Or(n, big.NewInt(int64(i)))
+	}
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(n, big.NewInt(1<<checksumBits-1)).Int64()
+	entropy := n.Rsh(n, uint(checksumBits)).FillBytes(make([]byte, checksumBits*4))
+	defer clear(entropy)
+	sum := sha256.Sum256(entropy)
+	if int64(sum[0]>>(8-checksumBits)) != checksum {
+		return errors.New("invalid mnemonic checksum")
+	}
+	return nil
+}
+
+// MnemonicSeed returns the 64-byte seed of a valid mnemonic and an optional
+// passphrase.
+func MnemonicSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	words := norm.NFKD.String(strings.Join(strings.Fields(mnemonic), " "))
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(words), []byte(salt), 2048, 64, sha512.New), nil
+}
@@ -0,0 +1,344 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// EVM transaction types.
+const (
+	TxLegacy     uint8 = 0
+	TxAccessList uint8 = 1 // EIP-2930
+	TxDynamicFee uint8 = 2 // EIP-1559
+)
+
+// TxRequest is the transaction a skill asks to sign, as JSON. Quantities
+// may be JSON numbers, decimal strings or 0x-prefixed hex strings. Without
+// "type", transactions with fee caps are EIP-1559, those with an access
+// list EIP-2930 and others legacy.
+type TxRequest struct {
+	Type                 *quantity     `json:"type,omitempty"`
+	ChainID              *quantity     `json:"chainId"`
+	Nonce                *quantity     `json:"nonce"`
+	To                   *address      `json:"to,omitempty"`
+	Value                *quantity     `json:"value,omitempty"`
+	Data                 hexBytes      `json:"data,omitempty"`
+	Gas                  *quantity     `json:"gas,omitempty"`
+	GasLimit             *quantity     `json:"gasLimit,omitempty"`
+	GasPrice             *quantity     `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *quantity     `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *quantity     `json:"maxPriorityFeePerGas,omitempty"`
+	AccessList           *[]AccessItem `json:"accessList,omitempty"`
+}
+
+// AccessItem is an entry of an EIP-2930 access list.
+type AccessItem struct {
+	Address     address    `json:"address"`
+	StorageKeys []hexBytes `json:"storageKeys"`
+}
+
+// SignedTx is a signed transaction, ready to broadcast.
+type SignedTx struct {
+	Type uint8
+	// From is the EIP-55 address of the signer.
+	From string
+	// Raw is the signed transaction as sent to eth_sendRawTransaction.
+	Raw []byte
+	// Hash is the transaction hash.
+	Hash []byte
+}
+
+// RawHex returns the raw transaction as 0x-prefixed hex.
+func (t *SignedTx) RawHex() string {
+	return "0x" + hex.EncodeToString(t.Raw)
+}
+
+// HashHex returns the transaction hash as 0x-prefixed hex.
+func (t *SignedTx) HashHex() string {
+	return "0x" + hex.EncodeToString(t.Hash)
+}
+
+// ParseTxRequest decodes and checks a transaction request.
+func ParseTxRequest(data []byte) (*TxRequest, error) {
+	var tx TxRequest
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	if err := tx.validate(); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// TxType returns the type of the transaction.
+func (tx *TxRequest) TxType() uint8 {
+	switch {
+	case tx.Type != nil:
+		return uint8(tx.Type.big().Uint64())
+	case tx.MaxFeePerGas != nil || tx.MaxPriorityFeePerGas != nil:
+		return TxDynamicFee
+	case tx.AccessList != nil:
+		return TxAccessList
+	}
+	return TxLegacy
+}
+
+func (tx *TxRequest) validate() error {
+	if tx.Type != nil && (!tx.Type.big().IsUint64() || tx.Type.big().Uint64() > uint64(TxDynamicFee)) {
+		return fmt.Errorf("unsupported type %s", tx.Type.big())
+	}
+	if tx.ChainID == nil || tx.ChainID.big().Sign() == 0 {
+		// EIP-155: every signature is bound to a chain.
+		return errors.New("chainId is required")
+	}
+	if tx.Nonce == nil {
+		return errors.New("nonce is required")
+	}
+	if !tx.Nonce.big().IsUint64() {
+		return errors.New("nonce out of range")
+	}
+	if tx.Gas != nil && tx.GasLimit != nil && tx.Gas.big().Cmp(tx.GasLimit.big()) != 0 {
+		return errors.New("gas and gasLimit differ")
+	}
+	if gas := tx.gas(); gas == nil || gas.Sign() == 0 || !gas.IsUint64() {
+		return errors.New("gas is required")
+	}
+	if tx.To == nil && len(tx.Data) == 0 {
+		return errors.New("a transaction without to must deploy code in data")
+	}
+
+	switch tx.TxType() {
+	case TxLegacy:
+		if tx.AccessList != nil {
+			return errors.New("legacy transactions have no access list")
+		}
+		fallthrough
+	case TxAccessList:
+		if tx.GasPrice == nil {
+			return errors.New("gasPrice is required")
+		}
+		if tx.MaxFeePerGas != nil || tx.MaxPriorityFeePerGas != nil {
+			return errors.New("fee caps are only allowed in EIP-1559 transactions")
+		}
+	case TxDynamicFee:
+		if tx.MaxFeePerGas == nil || tx.MaxPriorityFeePerGas == nil {
+			return errors.New("maxFeePerGas and maxPriorityFeePerGas are required")
+		}
+		if tx.GasPrice != nil {
+			return errors.New("gasPrice is not allowed in EIP-1559 transactions")
+		}
+		if tx.MaxPriorityFeePerGas.big().Cmp(tx.MaxFeePerGas.big()) > 0 {
+			return errors.New("maxPriorityFeePerGas exceeds maxFeePerGas")
+		}
+	}
+	for _, item := range tx.accessList() {
+		for _, key := range item.StorageKeys {
+			if len(key) != 32 {
+				return fmt.Errorf("storage key of %d bytes", len(key))
+			}
+		}
+	}
+	return nil
+}
+
+func (tx *TxRequest) gas() *big.Int {
+	if tx.GasLimit != nil {
+		return tx.GasLimit.big()
+	}
+	return tx.Gas.big()
+}
+
+func (tx *TxRequest) accessList() []AccessItem {
+	if tx.AccessList == nil {
+		return nil
+	}
+	return *tx.AccessList
+}
+
+// fields returns the RLP fields common to the signing payload and the
+// signed transaction, by type.
+func (tx *TxRequest) fields() []any {
+	to := []byte{}
+	if tx.To != nil {
+		to = tx.To[:]
+	}
+	value, data := tx.Value.big(), []byte(tx.Data)
+	nonce := tx.Nonce.big().Uint64()
+	gas := tx.gas().Uint64()
+
+	var accessList []any
+	for _, item := range tx.accessList() {
+		keys := []any{}
+		for _, key := range item.StorageKeys {
+			keys = append(keys, []byte(key))
+		}
+		accessList = append(accessList, []any{item.Address[:], keys})
+	}
+	if accessList == nil {
+		accessList = []any{}
+	}
+
+	switch tx.TxType() {
+	case TxAccessList:
+		return []any{tx.ChainID.big(), nonce, tx.GasPrice.big(), gas, to, value, data, accessList}
+	case TxDynamicFee:
+		return []any{tx.ChainID.big(), nonce, tx.MaxPriorityFeePerGas.big(), tx.MaxFeePerGas.big(), gas, to, value, data, accessList}
+	}
+	return []any{nonce, tx.GasPrice.big(), gas, to, value, data}
+}
+
+// SigningHash returns the hash that is signed: for legacy transactions the
+// EIP-155 hash including the chain ID, for typed ones the hash of the type
+// byte and payload.
+func (tx *TxRequest) SigningHash() []byte {
+	fields := tx.fields()
+	if tx.TxType() == TxLegacy {
+		return keccak256(rlpEncode(append(fields, tx.ChainID.big(), uint64(0), uint64(0))))
+	}
+	return keccak256([]byte{tx.TxType()}, rlpEncode(fields))
+}
+
+// SignTx signs the transaction with the key and returns it encoded for
+// broadcast.
+func SignTx(tx *TxRequest, key *secp256k1.PrivateKey) (*SignedTx, error) {
+	// SignCompact yields a deterministic (RFC 6979), low-S signature as
+	// [27 + recovery id][r][s].
+	sig := ecdsa.SignCompact(key, tx.SigningHash(), false)
+	recovery, r, s := uint64(sig[0]-27), sig[1:33], sig[33:65]
+
+	fields := tx.fields()
+	var raw []byte
+	if t := tx.TxType(); t == TxLegacy {
+		// EIP-155: v = recovery id + chainId * 2 + 35.
+		v := new(big.Int).Mul(tx.ChainID.big(), big.NewInt(2))
+		v.Add(v, big.NewInt(int64(35+recovery)))
+		raw = rlpEncode(append(fields, v, trimZeros(r), trimZeros(s)))
+	} else {
+		raw = append([]byte{t}, rlpEncode(append(fields, recovery, trimZeros(r), trimZeros(s)))...)
+	}
+	return &SignedTx{
+		Type: tx.TxType(),
+		From: PubkeyAddress(key.PubKey()),
+		Raw:  raw,
+		Hash: keccak256(raw),
+	}, nil
+}
+
+// PubkeyAddress returns the EIP-55 checksummed address of a public key.
+func PubkeyAddress(pub *secp256k1.PublicKey) string {
+	return checksumAddress(keccak256(pub.SerializeUncompressed()[1:])[12:])
+}
+
+// checksumAddress formats a 20-byte address with the EIP-55 mixed-case
+// checksum.
+func checksumAddress(addr []byte) string {
+	lower := hex.EncodeToString(addr)
+	hash := keccak256([]byte(lower))
+	var sb strings.Builder
+	sb.WriteString("0x")
+	for i, c := range lower {
+		// Letters are upper case where the nibble of the hash is >= 8.
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			c -= 'a' - 'A'
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func trimZeros(b []byte) []byte {
+	return bytes.TrimLeft(b, "\x00")
+}
+
+// quantity is a non-negative integer in JSON: a number, a decimal string or
+// a 0x-prefixed hex string.
+type quantity big.Int
+
+func (q *quantity) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	v, ok := new(big.Int), false
+	switch {
+	case s == "0x":
+		ok = true
+	case strings.HasPrefix(s, "0x"):
+		v, ok = v.SetString(s[2:], 16)
+	default:
+		v, ok = v.SetString(s, 10)
+	}
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return fmt.Errorf("invalid quantity %s", data)
+	}
+	*q = quantity(*v)
+	return nil
+}
+
+// big returns the value; nil is zero.
+func (q *quantity) big() *big.Int {
+	if q == nil {
+		return new(big.Int)
+	}
+	return (*big.Int)(q)
+}
+
+// hexBytes is a 0x-prefixed hex string in JSON.
+type hexBytes []byte
+
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return fmt.Errorf("hex string %q lacks 0x prefix", s)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return fmt.Errorf("invalid hex string %q", s)
+	}
+	*h = b
+	return nil
+}
+
+// address is a 20-byte account address. Mixed-case addresses must carry a
+// valid EIP-55 checksum.
+type address [20]byte
+
+func (a *address) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return fmt.Errorf("invalid address %q", s)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return fmt.Errorf("invalid address %q", s)
+	}
+	if s[2:] != strings.ToLower(s[2:]) && s[2:] != strings.ToUpper(s[2:]) && checksumAddress(b) != s {
+		return fmt.Errorf("address %q has an invalid checksum", s)
+	}
+	copy(a[:], b)
+	return nil
+}
@@ -0,0 +1,176 @@
+package vault
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestRLP(t *testing.T) {
+	lorem := "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{[]byte("dog"), "83646f67"},
+		{[]any{[]byte("cat"), []byte("dog")}, "c88363617483646f67"},
+		{[]byte{}, "80"},
+		{[]any{}, "c0"},
+		{uint64(0), "80"},
+		{[]byte{0}, "00"},
+		{uint64(15), "0f"},
+		{uint64(1024), "820400"},
+		{new(big.Int).Lsh(big.NewInt(1), 64), "89010000000000000000"},
+		{[]any{[]any{}, []any{[]any{}}, []any{[]any{}, []any{[]any{}}}}, "c7c0c1c0c3c0c1c0"},
+		{[]byte(lorem), "b838" + hex.EncodeToString([]byte(lorem))},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(rlpEncode(tt.in)); got != tt.want {
+			t.Errorf("rlpEncode(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func mustKey(t *testing.T, h string) *secp256k1.PrivateKey {
+	t.Helper()
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secp256k1.PrivKeyFromBytes(b)
+}
+
+// The expected values were produced by go-ethereum's signers; the legacy one
+// is the example of EIP-155.
+func TestSignTx(t *testing.T) {
+	eip155Key := "4646464646464646464646464646464646464646464646464646464646464646"
+	otherKey := "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+	tests := []struct {
+		name    string
+		key     string
+		tx      string
+		typ     uint8
+		from    string
+		sigHash string
+		raw     string
+		hash    string
+	}{
+		{
+			name:    "legacy EIP-155",
+			key:     eip155Key,
+			tx:      `{"chainId": 1, "nonce": 9, "gasPrice": "20000000000", "gas": 21000, "to": "0x3535353535353535353535353535353535353535", "value": "1000000000000000000"}`,
+			typ:     TxLegacy,
+			from:    "0x9d8A62f656a8d1615C1294fd71e9CFb3E4855A4F",
+			sigHash: "daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53",
+			raw:     "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83",
+			hash:    "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788",
+		},
+		{
+			name: "EIP-2930 token transfer",
+			key:  otherKey,
+			tx: `{"chainId": "0x2105", "nonce": "3", "gasPrice": "0x59682f00", "gasLimit": 60000, "to": "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", "value": "0",
+				"data": "0xa9059cbb000000000000000000000000742d35cc6634c0532925a3b844bc454e4438f44e0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+				"accessList": [{"address": "0x3535353535353535353535353535353535353535", "storageKeys": [
+					"0x0000000000000000000000000000000000000000000000000000000000000001",
+					"0x0000000000000000000000000000000000000000000000000000000000000002"]}]}`,
+			typ:     TxAccessList,
+			from:    "0x71562b71999873DB5b286dF957af199Ec94617F7",
+			sigHash: "b07f5c04af96fabea30588f25bb5f4675a5211126ad2b0b19fbb4ff6d3f5551c",
+			raw:     "0x01f90108822105038459682f0082ea6094742d35cc6634c0532925a3b844bc454e4438f44e80b844a9059cbb000000000000000000000000742d35cc6634c0532925a3b844bc454e4438f44e0000000000000000000000000000000000000000000000000de0b6b3a7640000f85bf859943535353535353535353535353535353535353535f842a00000000000000000000000000000000000000000000000000000000000000001a0000000000000000000000000000000000000000000000000000000000000000201a0eb0955a17640171fd821b083730361d3bc52360c8b9f151d43e963c43c408db2a06b70b27b7a4a975ef170ab1e79a574ec3665cc4764805f0693bea00919983ff0",
+			hash:    "0x32ea65b4af84bd68384c0b6378a30adf888c34ade581ebf92542aa990d22431f",
+		},
+		{
+			name:    "EIP-1559 transfer",
+			key:     otherKey,
+			tx:      `{"chainId": 1, "nonce": 42, "maxPriorityFeePerGas": "2000000000", "maxFeePerGas": "30000000000", "gas": "0x5208", "to": "0x742d35cc6634c0532925a3b844bc454e4438f44e", "value": "1500000000000000000"}`,
+			typ:     TxDynamicFee,
+			from:    "0x71562b71999873DB5b286dF957af199Ec94617F7",
+			sigHash: "2bd2ff871d941f51b1259255cd4108f2a7b003792a4ade0e91e3fd84110ec13a",
+			raw:     "0x02f873012a84773594008506fc23ac0082520894742d35cc6634c0532925a3b844bc454e4438f44e8814d1120d7b16000080c001a0591eaf663174ada24217bb00aa1ca64fbb10c8c38d889d80e7d41a29db2181aea0049fa1c3253dfd9124045e52258a9f9bc968295fa610062d4b14f6b46a047551",
+			hash:    "0x52588442bfad6f6103d4670649caf653f796a50e699a6e2a01f7753a8b1ca66d",
+		},
+		{
+			name:    "EIP-1559 contract creation",
+			key:     otherKey,
+			tx:      `{"type": 2, "chainId": 11155111, "nonce": 0, "maxPriorityFeePerGas": 1, "maxFeePerGas": 1000000000, "gas": 200000, "data": "0x6080604052"}`,
+			typ:     TxDynamicFee,
+			from:    "0x71562b71999873DB5b286dF957af199Ec94617F7",
+			sigHash: "28d3cda99aaa9b91f0e032f99a95df4149f1e3c6d238bc1fe6ff0f4f4d27aefd",
+			raw:     "0x02f85b83aa36a78001843b9aca0083030d408080856080604052c001a08f358b61a0e3579a388327c27c729c8f7580d519131a8e3fdae552dcc4c6182fa047ccc21c51281116af5f8d02fd76996002a1fd129ae0d3ae4427c67523b21b74",
+			hash:    "0xc9858c21d4df1692c4505d70465fe6594c2f994e6b7b8dbdfa790195798ee01d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := ParseTxRequest([]byte(tt.tx))
+			if err != nil {
+				t.Fatalf("ParseTxRequest failed: %v", err)
+			}
+			if tx.TxType() != tt.typ {
+				t.Errorf("Expected type %d, got %d", tt.typ, tx.TxType())
+			}
+			if got := hex.EncodeToString(tx.SigningHash()); got != tt.sigHash {
+				t.Errorf("Signing hash = %s, want %s", got, tt.sigHash)
+			}
+			signed, err := SignTx(tx, mustKey(t, tt.key))
+			if err != nil {
+				t.Fatalf("SignTx failed: %v", err)
+			}
+			if signed.From != tt.from {
+				t.Errorf("From = %s, want %s", signed.From, tt.from)
+			}
+			if signed.RawHex() != tt.raw {
+				t.Errorf("Raw = %s, want %s", signed.RawHex(), tt.raw)
+			}
+			if signed.HashHex() != tt.hash {
+				t.Errorf("Hash = %s, want %s", signed.HashHex(), tt.hash)
+			}
+		})
+	}
+}
+
+func TestParseTxRequestErrors(t *testing.T) {
+	tests := []struct {
+		tx   string
+		want string
+	}{
+		{`{"nonce": 0, "gasPrice": 1, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "chainId is required"},
+		{`{"chainId": 1, "gasPrice": 1, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "nonce is required"},
+		{`{"chainId": 1, "nonce": 0, "gasPrice": 1, "to": "0x3535353535353535353535353535353535353535"}`, "gas is required"},
+		{`{"chainId": 1, "nonce": 0, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "gasPrice is required"},
+		{`{"chainId": 1, "nonce": 0, "gasPrice": 1, "gas": 21000}`, "without to"},
+		{`{"chainId": 1, "nonce": 0, "maxFeePerGas": 1, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "maxPriorityFeePerGas are required"},
+		{`{"chainId": 1, "nonce": 0, "maxFeePerGas": 1, "maxPriorityFeePerGas": 2, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "exceeds maxFeePerGas"},
+		{`{"chainId": 1, "nonce": 0, "gasPrice": 1, "gas": 21000, "to": "0x742d35cc6634C0532925a3b844Bc454e4438f44e"}`, "invalid checksum"},
+		{`{"chainId": 1, "nonce": 0, "gasPrice": 1, "gas": 21000, "to": "0x3535"}`, "invalid address"},
+		{`{"chainId": 1, "nonce": -1, "gasPrice": 1, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "invalid quantity"},
+		{`{"type": 3, "chainId": 1, "nonce": 0, "gasPrice": 1, "gas": 21000, "to": "0x3535353535353535353535353535353535353535"}`, "unsupported type"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTxRequest([]byte(tt.tx)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseTxRequest(%s) = %v, want error containing %q", tt.tx, err, tt.want)
+		}
+	}
+}
+
+func TestLocalVault(t *testing.T) {
+	v := NewLocalVault("test")
+	tx := []byte(`{"chainId": 1, "nonce": 9, "gasPrice": "20000000000", "gas": 21000, "to": "0x3535353535353535353535353535353535353535", "value": "1000000000000000000"}`)
+	if _, err := v.SignTransaction(tx); err != ErrLocked {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	key, _ := hex.DecodeString("4646464646464646464646464646464646464646464646464646464646464646")
+	if err := v.LoadKey(key); err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if addr, _ := v.Address(); addr != "0x9d8A62f656a8d1615C1294fd71e9CFb3E4855A4F" {
+		t.Errorf("Unexpected address %s", addr)
+	}
+	signed, err := v.SignTransaction(tx)
+	if err != nil || signed.HashHex() != "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788" {
+		t.Errorf("Unexpected signed transaction: %+v, %v", signed, err)
+	}
+}
@@ -0,0 +1,361 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Chain is a family of chains sharing a key type and BIP-44 coin type.
+type Chain string
+
+const (
+	ChainEVM    Chain = "evm"
+	ChainSolana Chain = "solana"
+)
+
+// hardened marks an index of a derivation path as hardened (').
+const hardened uint32 = 1 << 31
+
+// AccountPath returns the BIP-44 path of account n of a chain:
+// m/44'/60'/0'/0/n for EVM chains and m/44'/501'/n'/0' for Solana, as
+// MetaMask, Phantom and the Solana CLI derive them.
+func AccountPath(chain Chain, n uint32) (string, error) {
+	if n >= hardened {
+		return "", fmt.Errorf("account index %d out of range", n)
+	}
+	switch chain {
+	case ChainEVM:
+		return fmt.Sprintf("m/44'/60'/0'/0/%d", n), nil
+	case ChainSolana:
+		return fmt.Sprintf("m/44'/501'/%d'/0'", n), nil
+	}
+	return "", fmt.Errorf("unsupported chain %q", chain)
+}
+
+// ParsePath parses a derivation path such as m/44'/60'/0'/0/1; h marks
+// hardened indices like '.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q does not start with m", path)
+	}
+	indices := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if s, ok := strings.CutSuffix(part, "'"); ok {
+			part, offset = s, hardened
+		} else if s, ok := strings.CutSuffix(part, "h"); ok {
+			part, offset = s, hardened
+		}
+		i, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", part, path)
+		}
+		indices = append(indices, uint32(i)+offset)
+	}
+	return indices, nil
+}
+
+// DeriveSecp256k1 derives the private key at path from a seed with BIP-32.
+func DeriveSecp256k1(seed []byte, path []uint32) ([]byte, error) {
+	node := hmacSHA512([]byte("Bitcoin seed"), seed)
+	defer clear(node)
+	var key secp256k1.ModNScalar
+	if overflow := key.SetByteSlice(node[:32]); overflow || key.IsZero() {
+		return nil, errors.New("seed yields an invalid master key")
+	}
+	for _, i := range path {
+		var data []byte
+		if i >= hardened {
+			b := key.Bytes()
+			data = append([]byte{0}, b[:]...)
+			clear(b[:])
+		} else {
+			data = secp256k1.NewPrivateKey(&key).PubKey().SerializeCompressed()
+		}
+		data = binary.BigEndian.AppendUint32(data, i)
+		child := hmacSHA512(node[32:], data)
+		clear(data)
+		clear(node)
+		node = child
+
+		// BIP-32 skips to the next index in these cases, which happen with
+		// probability below 2^-127.
+		var tweak secp256k1.ModNScalar
+		if overflow := tweak.SetByteSlice(node[:32]); overflow {
+			return nil, fmt.Errorf("invalid child key at index %d", i)
+		}
+		key.Add(&tweak)
+		tweak.Zero()
+		if key.IsZero() {
+			return nil, fmt.Errorf("invalid child key at index %d", i)
+		}
+	}
+	b := key.Bytes()
+	key.Zero()
+	return b[:], nil
+}
+
+// DeriveEd25519 derives the ed25519 private key seed at path from a seed
+// with SLIP-0010, which only allows hardened indices.
+func DeriveEd25519(seed []byte, path []uint32) ([]byte, error) {
+	node := hmacSHA512([]byte("ed25519 seed"), seed)
+	for _, i := range path {
+		if i < hardened {
+			clear(node)
+			return nil, errors.New("ed25519 derivation paths must be hardened")
+		}
+		data := append([]byte{0}, node[:32]...)
+		data = binary.BigEndian.AppendUint32(data, i)
+		child := hmacSHA512(node[32:], data)
+		clear(data)
+		clear(node)
+		node = child
+	}
+	key := slices.Clone(node[:32])
+	clear(node)
+	return key, nil
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// deriveAccount derives the private key and address of account n.
+func deriveAccount(seed []byte, chain Chain, n uint32) (key []byte, account HDAccount, err error) {
+	path, err := AccountPath(chain, n)
+	if err != nil {
+		return nil, HDAccount{}, err
+	}
+	indices, err := ParsePath(path)
+	if err != nil {
+		return nil, HDAccount{}, err
+	}
+	account = HDAccount{Chain: chain, Index: n, Path: path}
+	switch chain {
+	case ChainEVM:
+		if key, err = DeriveSecp256k1(seed, indices); err != nil {
+			return nil, HDAccount{}, err
+		}
+		account.Address, err = keyAddress(key)
+	case ChainSolana:
+		if key, err = DeriveEd25519(seed, indices); err != nil {
+			return nil, HDAccount{}, err
+		}
+		priv := ed25519.NewKeyFromSeed(key)
+		account.Address = base58Encode(priv.Public().(ed25519.PublicKey))
+		clear(priv)
+	}
+	if err != nil {
+		clear(key)
+		return nil, HDAccount{}, err
+	}
+	return key, account, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes b in the Bitcoin base58 alphabet, as Solana
+// addresses are.
+func base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	slices.Reverse(out)
+	return string(out)
+}
+
+// HDAccount is an account derived from the seed of an HDWallet.
+type HDAccount struct {
+	Chain   Chain  `json:"chain"`
+	Index   uint32 `json:"index"`
+	Path    string `json:"path"`
+	Address string `json:"address"`
+}
+
+// HDWallet derives the accounts of several chains from one BIP-39 seed,
+// kept encrypted like a keystore file. The derived addresses are stored in
+// the clear, so they can be listed without the passphrase.
+type HDWallet struct {
+	path string
+	// ScryptN and ScryptP are the scrypt parameters the seed is encrypted
+	// with.
+	ScryptN int
+	ScryptP int
+
+	mu   sync.Mutex
+	file hdWalletFile
+}
+
+// hdWalletFile is the JSON layout of the wallet on disk.
+type hdWalletFile struct {
+	Version int `json:"version"`
+	// Crypto holds the 64-byte seed, encrypted as in a v3 key file.
+	Crypto   *cryptoJSON `json:"crypto,omitempty"`
+	Accounts []HDAccount `json:"accounts"`
+}
+
+// DefaultWalletPath returns the default HD wallet path.
+// Usually ~/.luccibot/wallet.json
+func DefaultWalletPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".luccibot", "wallet.json"), nil
+}
+
+// OpenHDWallet loads the wallet at path, which has no seed yet if the file
+// does not exist.
+func OpenHDWallet(path string) (*HDWallet, error) {
+	w := &HDWallet{path: path, ScryptN: StandardScryptN, ScryptP: StandardScryptP}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return w, nil
+		}
+		return nil, fmt.Errorf("failed to read wallet: %w", err)
+	}
+	if err := json.Unmarshal(data, &w.file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal wallet: %w", err)
+	}
+	if w.file.Version != 1 {
+		return nil, fmt.Errorf("unsupported wallet version %d", w.file.Version)
+	}
+	return w, nil
+}
+
+// Initialized reports whether the wallet has a seed.
+func (w *HDWallet) Initialized() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Crypto != nil
+}
+
+// Create stores the seed of a mnemonic and its optional BIP-39 passphrase,
+// encrypted under passphrase, and derives the first EVM account. A wallet
+// has a single seed.
+func (w *HDWallet) Create(mnemonic, mnemonicPassphrase, passphrase string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file.Crypto != nil {
+		return errors.New("wallet already has a seed")
+	}
+	seed, err := MnemonicSeed(mnemonic, mnemonicPassphrase)
+	if err != nil {
+		return err
+	}
+	defer clear(seed)
+	key, account, err := deriveAccount(seed, ChainEVM, 0)
+	if err != nil {
+		return err
+	}
+	clear(key)
+	c, err := encryptCrypto(seed, passphrase, w.ScryptN, w.ScryptP)
+	if err != nil {
+		return err
+	}
+	w.file = hdWalletFile{Version: 1, Crypto: &c, Accounts: []HDAccount{account}}
+	return w.save()
+}
+
+// Accounts returns the derived accounts in the order of derivation.
+func (w *HDWallet) Accounts() []HDAccount {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.file.Accounts)
+}
+
+// Derive derives the next account of a chain and saves the wallet.
+func (w *HDWallet) Derive(chain Chain, passphrase string) (HDAccount, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seed, err := w.seed(passphrase)
+	if err != nil {
+		return HDAccount{}, err
+	}
+	defer clear(seed)
+	var n uint32
+	for _, a := range w.file.Accounts {
+		if a.Chain == chain {
+			n++
+		}
+	}
+	key, account, err := deriveAccount(seed, chain, n)
+	if err != nil {
+		return HDAccount{}, err
+	}
+	clear(key)
+	w.file.Accounts = append(w.file.Accounts, account)
+	if err := w.save(); err != nil {
+		return HDAccount{}, err
+	}
+	return account, nil
+}
+
+// Key derives the secp256k1 private key of a derived EVM account; an empty
+// address selects the first one. The caller should clear the key once done.
+func (w *HDWallet) Key(addr, passphrase string) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	i := slices.IndexFunc(w.file.Accounts, func(a HDAccount) bool {
+		return a.Chain == ChainEVM && (addr == "" || strings.EqualFold(a.Address, addr))
+	})
+	if i < 0 {
+		if addr == "" {
+			return nil, ErrNoAccount
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoAccount, addr)
+	}
+	account := w.file.Accounts[i]
+	seed, err := w.seed(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(seed)
+	key, _, err := deriveAccount(seed, account.Chain, account.Index)
+	return key, err
+}
+
+// seed decrypts the seed; the caller holds mu.
+func (w *HDWallet) seed(passphrase string) ([]byte, error) {
+	if w.file.Crypto == nil {
+		return nil, errors.New("wallet has no seed; run `luccibot wallet create` or `luccibot wallet import`")
+	}
+	return decryptCrypto(*w.file.Crypto, passphrase)
+}
+
+// save writes the wallet; the caller holds mu.
+func (w *HDWallet) save() error {
+	data, err := json.MarshalIndent(w.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal wallet: %w", err)
+	}
+	return writeFileAtomic(w.path, data)
+}
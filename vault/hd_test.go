@@ -0,0 +1,162 @@
+package vault
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const abandonMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// Vectors of BIP-39 (English, passphrase "TREZOR").
+func TestMnemonic(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			abandonMnemonic,
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"8080808080808080808080808080808080808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+			"c0c519bd0e91a2ed54357d9d1ebef6f5af218a153624cf4f2da911a0ed8f7a09e2ef61af0aca007096df430022f7a2b6fb91661a9589097069720d015e4e982f",
+		},
+		{
+			"9e885d952ad362caeb4efe34a8e91bd2",
+			"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+			"274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028",
+		},
+	}
+	for _, tt := range tests {
+		entropy, _ := hex.DecodeString(tt.entropy)
+		if got := entropyMnemonic(entropy); got != tt.mnemonic {
+			t.Errorf("Mnemonic of %s = %q, want %q", tt.entropy, got, tt.mnemonic)
+		}
+		seed, err := MnemonicSeed(tt.mnemonic, "TREZOR")
+		if err != nil || hex.EncodeToString(seed) != tt.seed {
+			t.Errorf("Seed of %q = %x, %v", tt.mnemonic, seed, err)
+		}
+	}
+
+	for _, bits := range []int{128, 256} {
+		m, err := NewMnemonic(bits)
+		if err != nil {
+			t.Fatalf("NewMnemonic failed: %v", err)
+		}
+		if n := len(strings.Fields(m)); n != bits/32*3 {
+			t.Errorf("NewMnemonic(%d) has %d words", bits, n)
+		}
+		if err := ValidateMnemonic(m); err != nil {
+			t.Errorf("Generated mnemonic is invalid: %v", err)
+		}
+	}
+
+	for _, m := range []string{
+		strings.Replace(abandonMnemonic, "about", "abandon", 1),
+		strings.Replace(abandonMnemonic, "about", "aboot", 1),
+		"abandon abandon about",
+	} {
+		if err := ValidateMnemonic(m); err == nil {
+			t.Errorf("Expected %q to be invalid", m)
+		}
+	}
+}
+
+func TestDerive(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	// Test vector 1 of BIP-32.
+	path, err := ParsePath("m/0'/1/2h/2/1000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := DeriveSecp256k1(seed, nil); err != nil || hex.EncodeToString(key) != "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35" {
+		t.Errorf("Master key = %x, %v", key, err)
+	}
+	if key, err := DeriveSecp256k1(seed, path); err != nil || hex.EncodeToString(key) != "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8" {
+		t.Errorf("Key at %v = %x, %v", path, key, err)
+	}
+	// Test vector 1 of SLIP-0010 for ed25519.
+	path, _ = ParsePath("m/0'/1'/2'/2'/1000000000'")
+	if key, err := DeriveEd25519(seed, path); err != nil || hex.EncodeToString(key) != "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793" {
+		t.Errorf("ed25519 key at %v = %x, %v", path, key, err)
+	}
+	if _, err := DeriveEd25519(seed, []uint32{0}); err == nil {
+		t.Error("Expected unhardened ed25519 derivation to fail")
+	}
+
+	for _, p := range []string{"44'/60'", "m/x", "m/2147483648"} {
+		if _, err := ParsePath(p); err == nil {
+			t.Errorf("Expected ParsePath(%q) to fail", p)
+		}
+	}
+}
+
+func TestHDWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w, err := OpenHDWallet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.ScryptN, w.ScryptP = LightScryptN, LightScryptP
+	if _, err := w.Derive(ChainEVM, "pass"); err == nil {
+		t.Error("Expected Derive to fail without a seed")
+	}
+	if err := w.Create(abandonMnemonic, "", "pass"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := w.Create(abandonMnemonic, "", "pass"); err == nil {
+		t.Error("Expected a second Create to fail")
+	}
+	if _, err := w.Derive(ChainEVM, "wrong"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt, got %v", err)
+	}
+	for _, chain := range []Chain{ChainEVM, ChainSolana, ChainSolana} {
+		if _, err := w.Derive(chain, "pass"); err != nil {
+			t.Fatalf("Derive(%s) failed: %v", chain, err)
+		}
+	}
+
+	// The addresses MetaMask and Phantom show for this mnemonic.
+	want := []HDAccount{
+		{ChainEVM, 0, "m/44'/60'/0'/0/0", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{ChainEVM, 1, "m/44'/60'/0'/0/1", "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"},
+		{ChainSolana, 0, "m/44'/501'/0'/0'", "HAgk14JpMQLgt6rVgv7cBQFJWFto5Dqxi472uT3DKpqk"},
+		{ChainSolana, 1, "m/44'/501'/1'/0'", "Hh8QwFUA6MtVu1qAoq12ucvFHNwCcVTV7hpWjeY1Hztb"},
+	}
+	// Listing survives a reload and needs no passphrase.
+	w, err = OpenHDWallet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := w.Accounts()
+	if len(got) != len(want) {
+		t.Fatalf("Accounts = %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Account %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	v := NewLocalVault(strings.ToLower(want[1].Address))
+	if err := v.Unlock(w, "pass"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if addr, _ := v.Address(); addr != want[1].Address {
+		t.Errorf("Vault address %s, want %s", addr, want[1].Address)
+	}
+	if err := NewLocalVault(want[2].Address).Unlock(w, "pass"); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("Expected a Solana account not to unlock the EVM vault, got %v", err)
+	}
+}
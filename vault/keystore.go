@@ -0,0 +1,456 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters of new key files, as in geth: the standard ones take
+// about a second and 256 MB, the light ones are for tests and weak devices.
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	keyfileScryptR = 8
+	keyfileDKLen   = 32
+)
+
+var (
+	// ErrNoAccount is returned for an address without a key file.
+	ErrNoAccount = errors.New("no such account in the keystore")
+	// ErrDecrypt is returned when a key file does not open with the
+	// passphrase.
+	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+)
+
+// Account is a key file of the keystore.
+type Account struct {
+	// Address is the EIP-55 address of the key.
+	Address string
+	Path    string
+}
+
+// Keystore keeps secp256k1 private keys in a directory of Web3 Secret
+// Storage (v3) files, the format of geth and foundry: the key is encrypted
+// with AES-128-CTR under a key derived from the passphrase with scrypt or
+// PBKDF2, and authenticated with a Keccak-256 MAC. Keys are never written
+// in plaintext.
+type Keystore struct {
+	dir string
+	// ScryptN and ScryptP are the scrypt parameters of the files written.
+	ScryptN int
+	ScryptP int
+
+	mu sync.Mutex
+}
+
+// keyFile is the JSON layout of a v3 key file.
+type keyFile struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string          `json:"cipher"`
+	CipherText   string          `json:"ciphertext"`
+	CipherParams cipherParams    `json:"cipherparams"`
+	KDF          string          `json:"kdf"`
+	KDFParams    json.RawMessage `json:"kdfparams"`
+	MAC          string          `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+// kdfParams holds the parameters of both KDFs; scrypt uses N, R and P,
+// PBKDF2 C and PRF.
+type kdfParams struct {
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+}
+
+// DefaultKeystoreDir returns the default keystore directory.
+// Usually ~/.luccibot/keystore
+func DefaultKeystoreDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".luccibot", "keystore"), nil
+}
+
+// NewKeystore returns the keystore in dir, which is created on the first
+// write, with the standard scrypt parameters.
+func NewKeystore(dir string) *Keystore {
+	return &Keystore{dir: dir, ScryptN: StandardScryptN, ScryptP: StandardScryptP}
+}
+
+// Accounts returns the accounts of the keystore, oldest first. Files that
+// are not key files are skipped.
+func (ks *Keystore) Accounts() ([]Account, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	var accounts []Account
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(ks.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var kf keyFile
+		if json.Unmarshal(data, &kf) != nil || kf.Version != 3 {
+			continue
+		}
+		addr, err := hex.DecodeString(strings.TrimPrefix(kf.Address, "0x"))
+		if err != nil || len(addr) != 20 {
+			continue
+		}
+		accounts = append(accounts, Account{Address: checksumAddress(addr), Path: path})
+	}
+	// geth names files after their creation time.
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Path < accounts[j].Path })
+	return accounts, nil
+}
+
+// Find returns the account of an address, in any case, with or without 0x.
+func (ks *Keystore) Find(addr string) (Account, error) {
+	accounts, err := ks.Accounts()
+	if err != nil {
+		return Account{}, err
+	}
+	want := strings.ToLower(strings.TrimPrefix(addr, "0x"))
+	for _, a := range accounts {
+		if strings.ToLower(a.Address[2:]) == want {
+			return a, nil
+		}
+	}
+	return Account{}, fmt.Errorf("%w: %s", ErrNoAccount, addr)
+}
+
+// NewAccount generates a key and stores it under the passphrase.
+func (ks *Keystore) NewAccount(passphrase string) (Account, error) {
+	priv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return Account{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	defer priv.Zero()
+	key := priv.Serialize()
+	defer clear(key)
+	return ks.ImportKey(key, passphrase)
+}
+
+// ImportKey stores a 32-byte private key under the passphrase.
+func (ks *Keystore) ImportKey(key []byte, passphrase string) (Account, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	addr, err := keyAddress(key)
+	if err != nil {
+		return Account{}, err
+	}
+	if _, err := ks.Find(addr); err == nil {
+		return Account{}, fmt.Errorf("account %s already exists", addr)
+	}
+	data, err := EncryptKey(key, passphrase, ks.ScryptN, ks.ScryptP)
+	if err != nil {
+		return Account{}, err
+	}
+	name := fmt.Sprintf("UTC--%s--%s", time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z"), strings.ToLower(addr[2:]))
+	path := filepath.Join(ks.dir, name)
+	if err := writeFileAtomic(path, data); err != nil {
+		return Account{}, err
+	}
+	return Account{Address: addr, Path: path}, nil
+}
+
+// Import stores the key of a key file from another wallet, re-encrypted
+// under newPassphrase.
+func (ks *Keystore) Import(keyJSON []byte, passphrase, newPassphrase string) (Account, error) {
+	key, err := DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return Account{}, err
+	}
+	defer clear(key)
+	return ks.ImportKey(key, newPassphrase)
+}
+
+// Export returns the key file of an account, re-encrypted under
+// newPassphrase.
+func (ks *Keystore) Export(addr, passphrase, newPassphrase string) ([]byte, error) {
+	key, err := ks.Key(addr, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	return EncryptKey(key, newPassphrase, ks.ScryptN, ks.ScryptP)
+}
+
+// ChangePassword re-encrypts the key file of an account under
+// newPassphrase.
+func (ks *Keystore) ChangePassword(addr, passphrase, newPassphrase string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	account, err := ks.Find(addr)
+	if err != nil {
+		return err
+	}
+	key, err := ks.decrypt(account, passphrase)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+	data, err := EncryptKey(key, newPassphrase, ks.ScryptN, ks.ScryptP)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(account.Path, data)
+}
+
+// Key decrypts the private key of an account; an empty address selects the
+// oldest one. The caller should clear the key once done.
+func (ks *Keystore) Key(addr, passphrase string) ([]byte, error) {
+	if addr == "" {
+		accounts, err := ks.Accounts()
+		if err != nil {
+			return nil, err
+		}
+		if len(accounts) == 0 {
+			return nil, ErrNoAccount
+		}
+		return ks.decrypt(accounts[0], passphrase)
+	}
+	account, err := ks.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return ks.decrypt(account, passphrase)
+}
+
+func (ks *Keystore) decrypt(account Account, passphrase string) ([]byte, error) {
+	data, err := os.ReadFile(account.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return DecryptKey(data, passphrase)
+}
+
+// writeFileAtomic replaces a file atomically, readable only by the user.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// EncryptKey returns a v3 key file of the 32-byte private key, encrypted
+// under the passphrase with scrypt parameters n and p.
+func EncryptKey(key []byte, passphrase string, n, p int) ([]byte, error) {
+	addr, err := keyAddress(key)
+	if err != nil {
+		return nil, err
+	}
+	c, err := encryptCrypto(key, passphrase, n, p)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	// A version 4 UUID.
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	h := hex.EncodeToString(id)
+	return json.Marshal(keyFile{
+		Address: strings.ToLower(addr[2:]),
+		Crypto:  c,
+		ID:      h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:],
+		Version: 3,
+	})
+}
+
+// DecryptKey returns the private key of a v3 key file. The caller should
+// clear it once done.
+func DecryptKey(keyJSON []byte, passphrase string) ([]byte, error) {
+	var kf keyFile
+	if err := json.Unmarshal(keyJSON, &kf); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+	if kf.Version != 3 {
+		return nil, fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+	key, err := decryptCrypto(kf.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := keyAddress(key)
+	if err != nil {
+		clear(key)
+		return nil, err
+	}
+	if kf.Address != "" && !strings.EqualFold(strings.TrimPrefix(kf.Address, "0x"), addr[2:]) {
+		clear(key)
+		return nil, fmt.Errorf("key file is for %s but holds the key of %s", kf.Address, addr)
+	}
+	return key, nil
+}
+
+// encryptCrypto encrypts plaintext as the crypto section of a v3 key file.
+func encryptCrypto(plaintext []byte, passphrase string, n, p int) (cryptoJSON, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	for _, b := range [][]byte{salt, iv} {
+		if _, err := rand.Read(b); err != nil {
+			return cryptoJSON{}, err
+		}
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, n, keyfileScryptR, p, keyfileDKLen)
+	if err != nil {
+		return cryptoJSON{}, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer clear(derived)
+	ciphertext, err := aesCTR(derived[:16], iv, plaintext)
+	if err != nil {
+		return cryptoJSON{}, err
+	}
+	params, err := json.Marshal(kdfParams{DKLen: keyfileDKLen, Salt: hex.EncodeToString(salt), N: n, R: keyfileScryptR, P: p})
+	if err != nil {
+		return cryptoJSON{}, err
+	}
+	return cryptoJSON{
+		Cipher:       "aes-128-ctr",
+		CipherText:   hex.EncodeToString(ciphertext),
+		CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+		KDF:          "scrypt",
+		KDFParams:    params,
+		MAC:          hex.EncodeToString(keccak256(derived[16:32], ciphertext)),
+	}, nil
+}
+
+// decryptCrypto checks the MAC of the crypto section of a v3 key file and
+// returns its plaintext.
+func decryptCrypto(c cryptoJSON, passphrase string) ([]byte, error) {
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher %q", c.Cipher)
+	}
+	var params kdfParams
+	if err := json.Unmarshal(c.KDFParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid kdfparams: %w", err)
+	}
+	mac, err1 := hex.DecodeString(c.MAC)
+	iv, err2 := hex.DecodeString(c.CipherParams.IV)
+	ciphertext, err3 := hex.DecodeString(c.CipherText)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	derived, err := deriveKeyfileKey(c.KDF, params, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(derived)
+	if subtle.ConstantTimeCompare(keccak256(derived[16:32], ciphertext), mac) != 1 {
+		return nil, ErrDecrypt
+	}
+	return aesCTR(derived[:16], iv, ciphertext)
+}
+
+func deriveKeyfileKey(kdf string, params kdfParams, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	if params.DKLen < 32 {
+		return nil, fmt.Errorf("derived key length %d is too short", params.DKLen)
+	}
+	switch kdf {
+	case "scrypt":
+		key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		return key, nil
+	case "pbkdf2":
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF %q", params.PRF)
+		}
+		if params.C <= 0 {
+			return nil, errors.New("invalid PBKDF2 iteration count")
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, params.C, params.DKLen, sha256.New), nil
+	}
+	return nil, fmt.Errorf("unsupported KDF %q", kdf)
+}
+
+func aesCTR(key, iv, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, errors.New("invalid IV length")
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+// keyAddress returns the EIP-55 address of a 32-byte private key.
+func keyAddress(key []byte) (string, error) {
+	priv, err := parseKey(key)
+	if err != nil {
+		return "", err
+	}
+	defer priv.Zero()
+	return PubkeyAddress(priv.PubKey()), nil
+}
@@ -0,0 +1,138 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test vectors of the Web3 Secret Storage definition, and a key file
+// written by geth.
+var keyFileVectors = []struct {
+	name       string
+	json       string
+	passphrase string
+	key        string
+}{
+	{
+		name:       "pbkdf2",
+		json:       `{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"6087dab2f9fdbbfaddc31a909735c1e6"},"ciphertext":"5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46","kdf":"pbkdf2","kdfparams":{"c":262144,"dklen":32,"prf":"hmac-sha256","salt":"ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},"mac":"517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`,
+		passphrase: "testpassword",
+		key:        "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d",
+	},
+	{
+		name:       "scrypt",
+		json:       `{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"83dbcc02d8ccb40e466191a123791e0e"},"ciphertext":"d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c","kdf":"scrypt","kdfparams":{"dklen":32,"n":262144,"p":8,"r":1,"salt":"ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},"mac":"2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`,
+		passphrase: "testpassword",
+		key:        "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d",
+	},
+	{
+		name:       "geth",
+		json:       `{"address":"71562b71999873db5b286df957af199ec94617f7","crypto":{"cipher":"aes-128-ctr","ciphertext":"12e6ae4a02f1b77c5ba5f3d2e3b9867d2f6b5d0a0d2802d558220249746f68dc","cipherparams":{"iv":"504592de43f20dcde4887cb7b7c24aad"},"kdf":"scrypt","kdfparams":{"dklen":32,"n":4096,"p":6,"r":8,"salt":"e4ec8323d8b60661cc1d0277fbbedc9f5f63a156630f00da716ef2a54da69c7b"},"mac":"28d065211f858c8894ec984525ffdfccfd358821a9c457eaf60e6c450ecde5ba"},"id":"444d3533-43c4-47d9-bf31-0a86f2001e6e","version":3}`,
+		passphrase: "geth",
+		key:        "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291",
+	},
+}
+
+func TestDecryptKey(t *testing.T) {
+	for _, tt := range keyFileVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := DecryptKey([]byte(tt.json), tt.passphrase)
+			if err != nil {
+				t.Fatalf("DecryptKey failed: %v", err)
+			}
+			if hex.EncodeToString(key) != tt.key {
+				t.Errorf("Key = %x, want %s", key, tt.key)
+			}
+			if _, err := DecryptKey([]byte(tt.json), "wrong"); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Expected ErrDecrypt with a wrong passphrase, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	ks := NewKeystore(t.TempDir())
+	ks.ScryptN, ks.ScryptP = LightScryptN, LightScryptP
+
+	created, err := ks.NewAccount("first")
+	if err != nil {
+		t.Fatalf("NewAccount failed: %v", err)
+	}
+	key, _ := hex.DecodeString(keyFileVectors[2].key)
+	imported, err := ks.ImportKey(key, "second")
+	if err != nil {
+		t.Fatalf("ImportKey failed: %v", err)
+	}
+	if imported.Address != "0x71562b71999873DB5b286dF957af199Ec94617F7" {
+		t.Errorf("Unexpected address %s", imported.Address)
+	}
+	if _, err := ks.ImportKey(key, "again"); err == nil {
+		t.Error("Expected importing an existing account to fail")
+	}
+
+	accounts, err := ks.Accounts()
+	if err != nil || len(accounts) != 2 || accounts[0] != created || accounts[1] != imported {
+		t.Fatalf("Unexpected accounts %v, %v", accounts, err)
+	}
+	data, err := os.ReadFile(imported.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(keyFileVectors[2].key)) {
+		t.Error("Key file holds the plaintext key")
+	}
+	if info, _ := os.Stat(imported.Path); info.Mode().Perm() != 0600 {
+		t.Errorf("Key file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// Addresses are matched in any case.
+	if err := ks.ChangePassword(strings.ToLower(imported.Address), "second", "changed"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+	if _, err := ks.Key(imported.Address, "second"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected the old passphrase to fail, got %v", err)
+	}
+	if got, err := ks.Key(imported.Address, "changed"); err != nil || !bytes.Equal(got, key) {
+		t.Errorf("Key = %x, %v", got, err)
+	}
+
+	exported, err := ks.Export(imported.Address, "changed", "exported")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	other := NewKeystore(t.TempDir())
+	other.ScryptN, other.ScryptP = LightScryptN, LightScryptP
+	if account, err := other.Import(exported, "exported", "other"); err != nil || account.Address != imported.Address {
+		t.Errorf("Import = %v, %v", account, err)
+	}
+
+	if _, err := ks.Key("0x3535353535353535353535353535353535353535", "first"); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("Expected ErrNoAccount, got %v", err)
+	}
+}
+
+func TestLocalVaultUnlock(t *testing.T) {
+	ks := NewKeystore(t.TempDir())
+	ks.ScryptN, ks.ScryptP = LightScryptN, LightScryptP
+	v := NewLocalVault("")
+	if err := v.Unlock(ks, "pass"); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("Expected ErrNoAccount from an empty keystore, got %v", err)
+	}
+	key, _ := hex.DecodeString(keyFileVectors[2].key)
+	if _, err := ks.ImportKey(key, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Unlock(ks, "wrong"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt, got %v", err)
+	}
+	if err := v.Unlock(ks, "pass"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if addr, _ := v.Address(); addr != "0x71562b71999873DB5b286dF957af199Ec94617F7" {
+		t.Errorf("Unexpected address %s", addr)
+	}
+}
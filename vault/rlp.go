@@ -0,0 +1,62 @@
+package vault
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// rlpEncode encodes v with Recursive Length Prefix encoding. v is a []byte,
+// a uint64 or *big.Int (encoded big-endian without leading zeros), or a
+// []any list of such values.
+func rlpEncode(v any) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return rlpString(v)
+	case uint64:
+		return rlpString(trimUint(v))
+	case *big.Int:
+		if v == nil {
+			return rlpString(nil)
+		}
+		return rlpString(v.Bytes())
+	case []any:
+		var payload []byte
+		for _, item := range v {
+			payload = append(payload, rlpEncode(item)...)
+		}
+		return append(rlpHeader(0xc0, len(payload)), payload...)
+	default:
+		panic(fmt.Sprintf("rlp: unsupported type %T", v))
+	}
+}
+
+// rlpString encodes a byte string; single bytes below 0x80 are their own
+// encoding.
+func rlpString(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+// rlpHeader returns the prefix of a string (offset 0x80) or list (0xc0) of
+// n bytes.
+func rlpHeader(offset byte, n int) []byte {
+	if n < 56 {
+		return []byte{offset + byte(n)}
+	}
+	size := trimUint(uint64(n))
+	return append([]byte{offset + 55 + byte(len(size))}, size...)
+}
+
+// trimUint returns v big-endian without leading zeros; zero is empty.
+func trimUint(v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	i := 0
+	for i < len(b) && b[i] == 0 {
+		i++
+	}
+	return b[i:]
+}
@@ -0,0 +1,382 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv may hold the passphrase of the secret store for the
+// `luccibot secrets` commands. LucciBot itself unlocks the store along with
+// the vault.
+const PassphraseEnv = "LUCCIBOT_VAULT_PASSPHRASE"
+
+// scrypt parameters of the secret store key.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrSecretsLocked is returned when secrets are needed before the store has
+// been unlocked.
+var ErrSecretsLocked = errors.New("secret store is locked")
+
+// SecretStore keeps the credentials of skills, such as exchange API keys,
+// encrypted on disk. Each secret is sealed with AES-256-GCM under a key
+// derived from the passphrase with scrypt. Skills only receive the secrets
+// the user granted them, and every reveal is appended to an audit log.
+type SecretStore struct {
+	path      string
+	auditPath string
+
+	mu   sync.Mutex
+	file secretsFile
+	// key is nil while the store is locked.
+	key []byte
+	// grants maps a skill name to the secrets it may receive; loaded on
+	// unlock, since it is sealed like the secrets.
+	grants map[string]grant
+}
+
+// grant is the user's approval for a skill to receive secrets. It holds for
+// the files the skill had then, identified by their pin in the skill
+// lockfile, so that a skill replaced under the same name gets nothing.
+type grant struct {
+	Secrets []string `json:"secrets"`
+	Pin     string   `json:"pin"`
+}
+
+// secretsFile is the JSON layout of the store on disk.
+type secretsFile struct {
+	Salt []byte `json:"salt"`
+	// Check is a known value sealed with the key, which tells a wrong
+	// passphrase apart.
+	Check   []byte            `json:"check"`
+	Secrets map[string][]byte `json:"secrets"`
+	// Grants is the sealed JSON of the grants.
+	Grants []byte `json:"grants,omitempty"`
+}
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Skill     string    `json:"skill"`
+	RequestID string    `json:"request_id,omitempty"`
+	Secrets   []string  `json:"secrets"`
+	Granted   bool      `json:"granted"`
+	Error     string    `json:"error,omitempty"`
+}
+
+const checkValue = "luccibot secret store"
+
+// DefaultSecretsPath returns the default secret store path.
+// Usually ~/.luccibot/secrets.json
+func DefaultSecretsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".luccibot", "secrets.json"), nil
+}
+
+// OpenSecretStore loads the locked store at path, starting empty if it does
+// not exist. Reveals are audited to secrets-audit.jsonl next to it.
+func OpenSecretStore(path string) (*SecretStore, error) {
+	s := &SecretStore{
+		path:      path,
+		auditPath: filepath.Join(filepath.Dir(path), "secrets-audit.jsonl"),
+		file:      secretsFile{Secrets: make(map[string][]byte)},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read secret store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret store: %w", err)
+	}
+	if s.file.Secrets == nil {
+		s.file.Secrets = make(map[string][]byte)
+	}
+	return s, nil
+}
+
+// Unlock derives the key from the passphrase. A new store takes the
+// passphrase it is first unlocked with.
+func (s *SecretStore) Unlock(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file.Salt == nil {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		key, err := deriveKey(passphrase, salt)
+		if err != nil {
+			return err
+		}
+		check, err := seal(key, []byte(checkValue), "check")
+		if err != nil {
+			return err
+		}
+		s.file.Salt, s.file.Check = salt, check
+		s.key, s.grants = key, make(map[string]grant)
+		return nil
+	}
+
+	key, err := deriveKey(passphrase, s.file.Salt)
+	if err != nil {
+		return err
+	}
+	if check, err := open(key, s.file.Check, "check"); err != nil || string(check) != checkValue {
+		return errors.New("wrong passphrase")
+	}
+	grants := make(map[string]grant)
+	if s.file.Grants != nil {
+		data, err := open(key, s.file.Grants, "grants")
+		if err != nil {
+			return fmt.Errorf("failed to open grants: %w", err)
+		}
+		if err := json.Unmarshal(data, &grants); err != nil {
+			return fmt.Errorf("failed to unmarshal grants: %w", err)
+		}
+	}
+	s.key, s.grants = key, grants
+	return nil
+}
+
+// Lock zeroizes the key and forgets the grants until the store is unlocked
+// again.
+func (s *SecretStore) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.key)
+	s.key, s.grants = nil, nil
+}
+
+// Initialized reports whether the store has a passphrase yet.
+func (s *SecretStore) Initialized() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Salt != nil
+}
+
+// Locked reports whether the store needs its passphrase.
+func (s *SecretStore) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.key == nil
+}
+
+// Names returns the names of the stored secrets, sorted.
+func (s *SecretStore) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.file.Secrets))
+	for name := range s.file.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set stores a secret and saves the store.
+func (s *SecretStore) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		return ErrSecretsLocked
+	}
+	sealed, err := seal(s.key, []byte(value), name)
+	if err != nil {
+		return err
+	}
+	s.file.Secrets[name] = sealed
+	return s.save()
+}
+
+// Delete removes a secret and saves the store.
+func (s *SecretStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.file.Secrets[name]; !ok {
+		return fmt.Errorf("secret %s is not set", name)
+	}
+	delete(s.file.Secrets, name)
+	return s.save()
+}
+
+// Grants returns the secrets the skill may receive.
+func (s *SecretStore) Grants(skill string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		return nil, ErrSecretsLocked
+	}
+	return slices.Clone(s.grants[skill].Secrets), nil
+}
+
+// Grant records the user's approval for the skill, as pinned to pin in the
+// skill lockfile, to receive the named secrets, replacing earlier grants,
+// and saves the store. No names revokes every grant of the skill.
+func (s *SecretStore) Grant(skill, pin string, names []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		return ErrSecretsLocked
+	}
+	if len(names) == 0 {
+		delete(s.grants, skill)
+	} else {
+		if pin == "" {
+			return fmt.Errorf("skill %s is not pinned; run `luccibot skills approve %s` first", skill, skill)
+		}
+		s.grants[skill] = grant{Secrets: slices.Sorted(slices.Values(names)), Pin: pin}
+	}
+	data, err := json.Marshal(s.grants)
+	if err != nil {
+		return fmt.Errorf("failed to marshal grants: %w", err)
+	}
+	if s.file.Grants, err = seal(s.key, data, "grants"); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Reveal returns the named secrets for a run of the skill, whose files are
+// pinned to pin, provided the user granted them all to the skill as pinned
+// then. Every call is audited, refused ones included.
+func (s *SecretStore) Reveal(skill, pin, requestID string, names []string) (map[string]string, error) {
+	values, err := s.reveal(skill, pin, names)
+	entry := AuditEntry{Time: time.Now().UTC(), Skill: skill, RequestID: requestID, Secrets: names, Granted: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if auditErr := s.audit(entry); auditErr != nil {
+		// Secrets are never handed out unaudited.
+		return nil, auditErr
+	}
+	return values, err
+}
+
+func (s *SecretStore) reveal(skill, pin string, names []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		return nil, ErrSecretsLocked
+	}
+	g, ok := s.grants[skill]
+	if ok && g.Pin != pin {
+		return nil, fmt.Errorf("skill %s has changed since its secrets were approved; run `luccibot secrets approve %s`", skill, skill)
+	}
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		if !slices.Contains(g.Secrets, name) {
+			return nil, fmt.Errorf("skill %s is not approved for secret %s; run `luccibot secrets approve %s`", skill, name, skill)
+		}
+		sealed, ok := s.file.Secrets[name]
+		if !ok {
+			return nil, fmt.Errorf("secret %s is not set; run `luccibot secrets set %s`", name, name)
+		}
+		value, err := open(s.key, sealed, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+		}
+		values[name] = string(value)
+	}
+	return values, nil
+}
+
+// audit appends an entry to the audit log.
+func (s *SecretStore) audit(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.auditPath), 0700); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	f, err := os.OpenFile(s.auditPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open secret audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write secret audit log: %w", err)
+	}
+	return nil
+}
+
+// save writes the store; the caller holds mu.
+func (s *SecretStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create secret store directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret store: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	return nil
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, binding it to label so that
+// sealed values cannot be swapped; the nonce is prepended.
+func seal(key, plaintext []byte, label string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, []byte(label)), nil
+}
+
+// open decrypts a value sealed with the same key and label.
+func open(key, sealed []byte, label string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte(label))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,168 @@
+package vault
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	s, err := OpenSecretStore(path)
+	if err != nil {
+		t.Fatalf("OpenSecretStore failed: %v", err)
+	}
+	if err := s.Set("CEX_KEY", "k"); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("Expected the locked store to refuse secrets, got %v", err)
+	}
+	if err := s.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := s.Set("CEX_KEY", "api-key-123"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := s.Set("CEX_SECRET", "api-secret-456"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := s.Grant("trade", "pin-1", []string{"CEX_KEY", "CEX_SECRET"}); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+
+	// Nothing is stored in plaintext.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "api-key-123") || strings.Contains(string(data), "trade") {
+		t.Errorf("Expected secrets and grants to be encrypted, got %s", data)
+	}
+
+	reopened, err := OpenSecretStore(path)
+	if err != nil {
+		t.Fatalf("OpenSecretStore failed: %v", err)
+	}
+	if err := reopened.Unlock("wrong"); err == nil {
+		t.Error("Expected a wrong passphrase to be refused")
+	}
+	if !reopened.Locked() {
+		t.Error("Expected the store to stay locked")
+	}
+	if _, err := reopened.Reveal("trade", "pin-1", "r0", []string{"CEX_KEY"}); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("Expected the locked store to refuse reveals, got %v", err)
+	}
+	if err := reopened.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	values, err := reopened.Reveal("trade", "pin-1", "r1", []string{"CEX_KEY", "CEX_SECRET"})
+	if err != nil || values["CEX_KEY"] != "api-key-123" || values["CEX_SECRET"] != "api-secret-456" {
+		t.Errorf("Unexpected secrets: %v, %v", values, err)
+	}
+	if _, err := reopened.Reveal("other", "pin-1", "r2", []string{"CEX_KEY"}); err == nil || !strings.Contains(err.Error(), "not approved for secret CEX_KEY") {
+		t.Errorf("Expected an ungranted skill to be refused, got %v", err)
+	}
+	if err := reopened.Grant("trade", "", nil); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	if _, err := reopened.Reveal("trade", "pin-1", "r3", []string{"CEX_KEY"}); err == nil {
+		t.Error("Expected revoked secrets to be refused")
+	}
+
+	// Every reveal is audited, refused ones included, without the values.
+	f, err := os.Open(filepath.Join(filepath.Dir(path), "secrets-audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), "api-key-123") {
+			t.Errorf("Audit log leaks a secret: %s", scanner.Text())
+		}
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 audit entries, got %d", len(entries))
+	}
+	if e := entries[1]; e.Skill != "trade" || e.RequestID != "r1" || !e.Granted || len(e.Secrets) != 2 {
+		t.Errorf("Unexpected audit entry: %+v", e)
+	}
+	if e := entries[2]; e.Skill != "other" || e.Granted || e.Error == "" {
+		t.Errorf("Unexpected audit entry: %+v", e)
+	}
+}
+
+func TestSecretGrantPin(t *testing.T) {
+	s, err := OpenSecretStore(filepath.Join(t.TempDir(), "secrets.json"))
+	if err != nil {
+		t.Fatalf("OpenSecretStore failed: %v", err)
+	}
+	if err := s.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := s.Set("CEX_KEY", "api-key-123"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := s.Grant("trade", "", []string{"CEX_KEY"}); err == nil {
+		t.Error("Expected a grant to an unpinned skill to fail")
+	}
+	if err := s.Grant("trade", "pin-1", []string{"CEX_KEY"}); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+
+	// A skill swapped under the same name has another pin, or none.
+	for _, pin := range []string{"pin-2", ""} {
+		if _, err := s.Reveal("trade", pin, "r1", []string{"CEX_KEY"}); err == nil || !strings.Contains(err.Error(), "has changed since its secrets were approved") {
+			t.Errorf("Expected the swapped skill with pin %q to be refused, got %v", pin, err)
+		}
+	}
+	if _, err := s.Reveal("trade", "pin-1", "r2", []string{"CEX_KEY"}); err != nil {
+		t.Errorf("Expected the approved skill to get its secrets, got %v", err)
+	}
+}
+
+func TestSecretStoreLock(t *testing.T) {
+	s, err := OpenSecretStore(filepath.Join(t.TempDir(), "secrets.json"))
+	if err != nil {
+		t.Fatalf("OpenSecretStore failed: %v", err)
+	}
+	if err := s.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := s.Set("CEX_KEY", "api-key-123"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := s.Grant("trade", "pin-1", []string{"CEX_KEY"}); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+
+	key := s.key
+	s.Lock()
+	if !s.Locked() || s.grants != nil {
+		t.Error("Expected Lock to drop the key and grants")
+	}
+	for _, b := range key {
+		if b != 0 {
+			t.Fatal("Expected the key to be zeroized")
+		}
+	}
+	if _, err := s.Reveal("trade", "pin-1", "r1", []string{"CEX_KEY"}); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("Expected the locked store to refuse reveals, got %v", err)
+	}
+
+	if err := s.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if values, err := s.Reveal("trade", "pin-1", "r2", []string{"CEX_KEY"}); err != nil || values["CEX_KEY"] != "api-key-123" {
+		t.Errorf("Unexpected secrets after unlocking again: %v, %v", values, err)
+	}
+}
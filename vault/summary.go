@@ -0,0 +1,165 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// TxSummary describes a transaction request in the terms the user confirms
+// it in.
+type TxSummary struct {
+	// Chain is the name of the chain, or "chain <id>" for unknown ones.
+	Chain   string
+	ChainID string
+	// To is the recipient: the address receiving tokens or an allowance
+	// for the token calls below, otherwise the to address. It is empty
+	// for contract deployments.
+	To string
+	// Amount is the amount moved in Token: a decimal number of the native
+	// coin, or an integer of token base units when Token is the address of
+	// an ERC-20 contract.
+	Amount string
+	Token  string
+	// GasCost is the most the transaction can spend on gas, in Native.
+	GasCost string
+	Native  string
+	// Calldata summarizes the data of the transaction; empty for plain
+	// transfers.
+	Calldata string
+}
+
+// chainInfo names the chains a summary knows, and their native coin.
+var chainInfo = map[uint64]struct{ name, native string }{
+	1:        {"Ethereum", "ETH"},
+	10:       {"OP Mainnet", "ETH"},
+	56:       {"BNB Smart Chain", "BNB"},
+	137:      {"Polygon", "POL"},
+	8453:     {"Base", "ETH"},
+	42161:    {"Arbitrum One", "ETH"},
+	43114:    {"Avalanche C-Chain", "AVAX"},
+	11155111: {"Sepolia", "ETH"},
+}
+
+// ERC-20 calls a summary decodes, by selector.
+var (
+	selectorTransfer     = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	selectorApprove      = []byte{0x09, 0x5e, 0xa7, 0xb3}
+	selectorTransferFrom = []byte{0x23, 0xb8, 0x72, 0xdd}
+)
+
+// maxUint256 is the allowance approve calls use for "unlimited".
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// Summary describes the transaction: its recipient, amount, token, chain,
+// maximum gas cost and calldata. ERC-20 transfer, transferFrom and approve
+// calls are decoded to the token recipient and amount.
+func (tx *TxRequest) Summary() TxSummary {
+	chainID := tx.ChainID.big()
+	s := TxSummary{
+		Chain:   "chain " + chainID.String(),
+		ChainID: chainID.String(),
+		Native:  "native coin",
+	}
+	if chainID.IsUint64() {
+		if info, ok := chainInfo[chainID.Uint64()]; ok {
+			s.Chain, s.Native = info.name, info.native
+		}
+	}
+
+	price := tx.GasPrice
+	if tx.TxType() == TxDynamicFee {
+		price = tx.MaxFeePerGas
+	}
+	s.GasCost = formatUnits(new(big.Int).Mul(tx.gas(), price.big()), 18)
+
+	value := new(big.Int)
+	if tx.Value != nil {
+		value = tx.Value.big()
+	}
+	s.Amount, s.Token = formatUnits(value, 18), s.Native
+
+	if tx.To == nil {
+		s.Calldata = fmt.Sprintf("deploys a contract (%d bytes of code)", len(tx.Data))
+		return s
+	}
+	s.To = checksumAddress(tx.To[:])
+	if len(tx.Data) == 0 {
+		return s
+	}
+	s.Calldata = fmt.Sprintf("calls 0x%x with %d bytes of arguments", tx.Data[:min(4, len(tx.Data))], max(0, len(tx.Data)-4))
+	if call, ok := decodeTokenCall(tx.Data); ok {
+		s.Calldata = call.signature
+		if call.from != "" {
+			s.Calldata += " from " + call.from
+		}
+		s.Token, s.To = s.To, call.to
+		s.Amount = call.amount.String()
+		if call.amount.Cmp(maxUint256) == 0 {
+			s.Amount = "unlimited"
+		}
+	}
+	if value.Sign() > 0 && s.Token != s.Native {
+		s.Calldata += fmt.Sprintf(", sending %s %s along", formatUnits(value, 18), s.Native)
+	}
+	return s
+}
+
+// tokenCall is a decoded ERC-20 call.
+type tokenCall struct {
+	signature string
+	from, to  string
+	amount    *big.Int
+}
+
+// decodeTokenCall decodes ERC-20 transfer, transferFrom and approve calls.
+func decodeTokenCall(data []byte) (tokenCall, bool) {
+	if len(data) < 4 {
+		return tokenCall{}, false
+	}
+	selector, args := data[:4], data[4:]
+	var call tokenCall
+	var words int
+	switch {
+	case bytes.Equal(selector, selectorTransfer):
+		call.signature, words = "transfer(address,uint256)", 2
+	case bytes.Equal(selector, selectorApprove):
+		call.signature, words = "approve(address,uint256)", 2
+	case bytes.Equal(selector, selectorTransferFrom):
+		call.signature, words = "transferFrom(address,address,uint256)", 3
+	default:
+		return tokenCall{}, false
+	}
+	if len(args) != words*32 {
+		return tokenCall{}, false
+	}
+	var addrs []string
+	for i := 0; i < words-1; i++ {
+		word := args[i*32 : (i+1)*32]
+		if !bytes.Equal(word[:12], make([]byte, 12)) {
+			return tokenCall{}, false
+		}
+		addrs = append(addrs, checksumAddress(word[12:]))
+	}
+	if len(addrs) == 2 {
+		call.from = addrs[0]
+	}
+	call.to = addrs[len(addrs)-1]
+	call.amount = new(big.Int).SetBytes(args[(words-1)*32:])
+	return call, true
+}
+
+// formatUnits formats an integer amount of base units with the given
+// number of decimals, without trailing zeros.
+func formatUnits(v *big.Int, decimals int) string {
+	s := v.String()
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-decimals], strings.TrimRight(s[len(s)-decimals:], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
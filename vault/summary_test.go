@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTxSummary(t *testing.T) {
+	// transfer(0x3535…35, 1000000) on USDC
+	transfer := "0xa9059cbb" + strings.Repeat("0", 24) + strings.Repeat("35", 20) + strings.Repeat("0", 58) + "0f4240"
+	approve := "0x095ea7b3" + strings.Repeat("0", 24) + strings.Repeat("35", 20) + strings.Repeat("f", 64)
+	tests := []struct {
+		name string
+		tx   string
+		want TxSummary
+	}{
+		{
+			"native transfer",
+			`{"chainId": 1, "nonce": 9, "gasPrice": "20000000000", "gas": 21000, "to": "0x3535353535353535353535353535353535353535", "value": "1500000000000000000"}`,
+			TxSummary{Chain: "Ethereum", ChainID: "1", To: "0x3535353535353535353535353535353535353535", Amount: "1.5", Token: "ETH", GasCost: "0.00042", Native: "ETH"},
+		},
+		{
+			"token transfer",
+			`{"chainId": 8453, "nonce": 0, "maxFeePerGas": "0x3b9aca00", "maxPriorityFeePerGas": 1, "gas": 60000, "to": "0x833589fcd6edb6e08f4c7c32d4f71b54bda02913", "data": "` + transfer + `"}`,
+			TxSummary{Chain: "Base", ChainID: "8453", To: "0x3535353535353535353535353535353535353535", Amount: "1000000", Token: "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", GasCost: "0.00006", Native: "ETH", Calldata: "transfer(address,uint256)"},
+		},
+		{
+			"unlimited approval",
+			`{"chainId": 137, "nonce": 0, "gasPrice": 1, "gas": 50000, "to": "0x3c499c542cef5e3811e1192ce70d8cc03d5c3359", "data": "` + approve + `"}`,
+			TxSummary{Chain: "Polygon", ChainID: "137", To: "0x3535353535353535353535353535353535353535", Amount: "unlimited", Token: "0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359", GasCost: "0.00000000000005", Native: "POL", Calldata: "approve(address,uint256)"},
+		},
+		{
+			"contract call",
+			`{"chainId": 999, "nonce": 0, "gasPrice": 1, "gas": 100000, "to": "0x3535353535353535353535353535353535353535", "value": 1, "data": "0xd0e30db0"}`,
+			TxSummary{Chain: "chain 999", ChainID: "999", To: "0x3535353535353535353535353535353535353535", Amount: "0.000000000000000001", Token: "native coin", GasCost: "0.0000000000001", Native: "native coin", Calldata: "calls 0xd0e30db0 with 0 bytes of arguments"},
+		},
+		{
+			"deployment",
+			`{"chainId": 1, "nonce": 0, "gasPrice": 1, "gas": 100000, "data": "0x6000"}`,
+			TxSummary{Chain: "Ethereum", ChainID: "1", Amount: "0", Token: "ETH", GasCost: "0.0000000000001", Native: "ETH", Calldata: "deploys a contract (2 bytes of code)"},
+		},
+	}
+	for _, tt := range tests {
+		tx, err := ParseTxRequest([]byte(tt.tx))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := tx.Summary(); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,37 +2,164 @@ package vault
 
 import (
 	"errors"
-	"fmt"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
+// ErrLocked is returned when signing while the vault holds no key: before
+// it is unlocked, and again once it locks.
+var ErrLocked = errors.New("vault locked")
+
+// ErrRejected is returned for signatures the user rejected or did not
+// approve in time.
+var ErrRejected = errors.New("signature rejected")
+
+// DefaultIdleTimeout is the inactivity after which LockIfIdle locks the
+// vault, unless IdleTimeout is set.
+const DefaultIdleTimeout = 5 * time.Minute
+
+// DefaultApprovalTimeout is how long a signature waits for the user's
+// approval before it is rejected.
+const DefaultApprovalTimeout = 2 * time.Minute
+
 // Vault defines the interface for secure operations.
 type Vault interface {
-	SignTransaction(txData []byte) ([]byte, error)
+	SignTransaction(txData []byte) (*SignedTx, error)
 }
 
-// LocalVault is a implementation of Vault that (eventually) uses the OS Keychain.
+// LocalVault is an implementation of Vault that signs EVM transactions with
+// a secp256k1 key held in memory. It starts locked, without a key.
 type LocalVault struct {
-	// Placeholder for future OS Keychain integration fields
+	// keyID is the address of the account to sign with; empty selects the
+	// default account of the key source.
 	keyID string
+	// IdleTimeout is the time since the vault was unlocked or last signed
+	// after which LockIfIdle locks it; zero or less never locks.
+	IdleTimeout time.Duration
+
+	mu  sync.Mutex
+	key *secp256k1.PrivateKey
+	// lastUsed is when the key was loaded or last signed.
+	lastUsed time.Time
 }
 
-// NewLocalVault creates a new instance of LocalVault.
+// NewLocalVault creates a new instance of LocalVault, locked.
 func NewLocalVault(keyID string) *LocalVault {
 	return &LocalVault{
-		keyID: keyID,
+		keyID:       keyID,
+		IdleTimeout: DefaultIdleTimeout,
+	}
+}
+
+// LoadKey makes the vault sign with the 32-byte secp256k1 private key.
+func (v *LocalVault) LoadKey(key []byte) error {
+	priv, err := parseKey(key)
+	if err != nil {
+		return err
 	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key != nil {
+		v.key.Zero()
+	}
+	v.key, v.lastUsed = priv, time.Now()
+	return nil
+}
+
+// Lock zeroizes the key; signing fails with ErrLocked until the vault is
+// unlocked again.
+func (v *LocalVault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.lock()
 }
 
-// SignTransaction currently returns a mock signature.
-func (v *LocalVault) SignTransaction(txData []byte) ([]byte, error) {
+// LockIfIdle locks the vault when it has not signed for IdleTimeout, and
+// reports whether it did.
+func (v *LocalVault) LockIfIdle(now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil || v.IdleTimeout <= 0 || now.Sub(v.lastUsed) < v.IdleTimeout {
+		return false
+	}
+	v.lock()
+	return true
+}
+
+// Locked reports whether the vault holds no key.
+func (v *LocalVault) Locked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key == nil
+}
+
+// lock zeroizes the key; the caller holds mu.
+func (v *LocalVault) lock() {
+	if v.key != nil {
+		v.key.Zero()
+		v.key = nil
+	}
+}
+
+// KeySource holds encrypted keys: a Keystore or an HDWallet.
+type KeySource interface {
+	// Key decrypts the private key of an account, or of the default
+	// account when addr is empty.
+	Key(addr, passphrase string) ([]byte, error)
+}
+
+// Unlock decrypts the key of the vault's account from src and loads it.
+func (v *LocalVault) Unlock(src KeySource, passphrase string) error {
+	key, err := src.Key(v.keyID, passphrase)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+	return v.LoadKey(key)
+}
+
+// Address returns the EIP-55 address of the loaded key.
+func (v *LocalVault) Address() (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	return PubkeyAddress(v.key.PubKey()), nil
+}
+
+// SignTransaction parses a transaction request (see TxRequest) and signs it
+// with EIP-155 replay protection. A locked vault fails with ErrLocked.
+func (v *LocalVault) SignTransaction(txData []byte) (*SignedTx, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return nil, ErrLocked
+	}
 	if len(txData) == 0 {
 		return nil, errors.New("transaction data is empty")
 	}
+	tx, err := ParseTxRequest(txData)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := SignTx(tx, v.key)
+	if err == nil {
+		v.lastUsed = time.Now()
+	}
+	return signed, err
+}
 
-	// TODO: Replace with actual signing logic using the key stored in OS Keychain.
-	// For now, we return a mock signature for architectural validation.
-	fmt.Printf("Vault: Signing data for key %s\n", v.keyID)
-	mockSignature := []byte(fmt.Sprintf("mock_sig_for_%s", string(txData)))
-	
-	return mockSignature, nil
-}
\ No newline at end of file
+// parseKey checks a 32-byte secp256k1 private key.
+func parseKey(key []byte) (*secp256k1.PrivateKey, error) {
+	if len(key) != 32 {
+		return nil, errors.New("private key must be 32 bytes")
+	}
+	var k secp256k1.ModNScalar
+	if overflow := k.SetByteSlice(key); overflow || k.IsZero() {
+		return nil, errors.New("invalid private key")
+	}
+	return secp256k1.NewPrivateKey(&k), nil
+}
@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalVaultLock(t *testing.T) {
+	v := NewLocalVault("")
+	if !v.Locked() {
+		t.Fatal("Expected a new vault to be locked")
+	}
+	key, _ := hex.DecodeString("4646464646464646464646464646464646464646464646464646464646464646")
+	if err := v.LoadKey(key); err != nil {
+		t.Fatal(err)
+	}
+	priv := v.key
+
+	// A malformed transaction is reported as such while unlocked, but a
+	// locked vault answers ErrLocked before looking at it.
+	if _, err := v.SignTransaction([]byte("{")); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+	v.Lock()
+	if !v.Locked() || !priv.Key.IsZero() {
+		t.Error("Expected Lock to zeroize the key")
+	}
+	if _, err := v.SignTransaction([]byte("{")); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if _, err := v.Address(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+}
+
+func TestLocalVaultLockIfIdle(t *testing.T) {
+	v := NewLocalVault("")
+	v.IdleTimeout = time.Minute
+	if v.LockIfIdle(time.Now().Add(time.Hour)) {
+		t.Error("Expected a locked vault not to lock again")
+	}
+	key, _ := hex.DecodeString("4646464646464646464646464646464646464646464646464646464646464646")
+	if err := v.LoadKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if v.LockIfIdle(time.Now().Add(30 * time.Second)) {
+		t.Error("Expected the vault to stay unlocked before the timeout")
+	}
+
+	// Signing resets the idle time.
+	v.lastUsed = time.Now().Add(-50 * time.Second)
+	tx := []byte(`{"chainId": 1, "nonce": 9, "gasPrice": "20000000000", "gas": 21000, "to": "0x3535353535353535353535353535353535353535", "value": "1000000000000000000"}`)
+	if _, err := v.SignTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	if v.LockIfIdle(time.Now().Add(30 * time.Second)) {
+		t.Error("Expected signing to reset the idle time")
+	}
+	if !v.LockIfIdle(time.Now().Add(time.Minute)) || !v.Locked() {
+		t.Error("Expected the vault to lock once idle")
+	}
+
+	v.IdleTimeout = 0
+	if err := v.LoadKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if v.LockIfIdle(time.Now().Add(24 * time.Hour)) {
+		t.Error("Expected no idle lock without a timeout")
+	}
+}